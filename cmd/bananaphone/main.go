@@ -0,0 +1,237 @@
+//bananaphone is a small triage tool for poking at ntdll with the bananaphone package: dump the sysids for the host, resolve a single function, or check the in-memory stubs for hooks.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Binject/debug/pe"
+	bananaphone "github.com/C-Sto/BananaPhone/pkg/BananaPhone"
+	"github.com/C-Sto/BananaPhone/pkg/BananaPhone/ntconst"
+	"github.com/C-Sto/BananaPhone/pkg/BananaPhone/syscalltable"
+	"github.com/awgh/rawreader"
+)
+
+//stubBytes is how much of a function resolve prints. A whole syscall stub on modern builds.
+const stubBytes = 0x20
+
+var (
+	mode    = flag.String("mode", "auto", "Which bananaphone mode to use for resolving sysids. Options: auto,memory,disk,halos")
+	genPkg  = flag.String("package", "main", "package name of the file written by gen")
+	genVar  = flag.String("var", "SysIDs", "variable name of the table written by gen")
+	genFile = flag.String("output", "", "file written by gen (standard output if omitted)")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `usage: bananaphone [flags] <command> [args]
+
+commands:
+  dump              print the sysid of every Nt*/Zw* export
+  resolve <name>    print the sysid, address and stub bytes of a function
+  hooks             print the in-memory Nt*/Zw* stubs that don't look like syscall stubs
+  report            try to resolve every Nt*/Zw* export and print the ones that failed
+  diff <old> <new>  print the syscalls added, removed and renumbered between two ntdll (or win32u) files. Works offline
+  gen <file>        write a Go file with the sysid table of an ntdll (or win32u) file, for WithSysIDOverrides. Works offline, on any platform
+
+flags:
+`)
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+	}
+	if flag.Arg(0) == "gen" {
+		if flag.NArg() != 2 {
+			usage()
+		}
+		if e := gen(flag.Arg(1)); e != nil {
+			fatal(e)
+		}
+		return
+	}
+	if flag.Arg(0) == "diff" {
+		if flag.NArg() != 3 {
+			usage()
+		}
+		d, e := syscalltable.CompareNtdll(flag.Arg(1), flag.Arg(2))
+		if e != nil {
+			fatal(e)
+		}
+		fmt.Print(d)
+		return
+	}
+
+	bp, e := bananaphone.NewBananaPhone(phoneMode(*mode))
+	if e != nil {
+		fatal(e)
+	}
+	defer bp.Close()
+
+	switch flag.Arg(0) {
+	case "dump":
+		e = dump(bp)
+	case "resolve":
+		if flag.NArg() != 2 {
+			usage()
+		}
+		e = resolve(bp, flag.Arg(1))
+	case "hooks":
+		e = hooks(bp)
+	case "report":
+		e = report(bp)
+	default:
+		usage()
+	}
+	if e != nil {
+		fatal(e)
+	}
+}
+
+func fatal(e error) {
+	fmt.Fprintln(os.Stderr, e)
+	os.Exit(1)
+}
+
+func phoneMode(s string) bananaphone.PhoneMode {
+	switch strings.ToLower(s) {
+	case "memory":
+		return bananaphone.MemoryBananaPhoneMode
+	case "disk":
+		return bananaphone.DiskBananaPhoneMode
+	case "halos":
+		return bananaphone.HalosGateBananaPhoneMode
+	}
+	return bananaphone.AutoBananaPhoneMode
+}
+
+//syscallExports returns the sorted Nt*/Zw* exports of the ntdll loaded in this process.
+func syscallExports() ([]pe.Export, uintptr, error) {
+	base, size, e := bananaphone.GetModuleBase("ntdll.dll")
+	if e != nil {
+		return nil, 0, e
+	}
+	p, e := pe.NewFileFromMemory(rawreader.New(base, int(size)))
+	if e != nil {
+		return nil, 0, e
+	}
+	exports, e := p.Exports()
+	if e != nil {
+		return nil, 0, e
+	}
+	ret := []pe.Export{}
+	for _, ex := range exports {
+		if strings.HasPrefix(ex.Name, "Nt") || strings.HasPrefix(ex.Name, "Zw") {
+			ret = append(ret, ex)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, base, nil
+}
+
+//dump prints every Nt*/Zw* export that resolves to a sysid. Exports that aren't syscalls (eg NtdllDefWindowProc_A) are skipped.
+func dump(bp *bananaphone.BananaPhone) error {
+	exports, _, e := syscallExports()
+	if e != nil {
+		return e
+	}
+	for _, ex := range exports {
+		id, e := bp.GetSysID(ex.Name)
+		if e != nil {
+			continue
+		}
+		fmt.Printf("%-50s %#04x\n", ex.Name, id)
+	}
+	return nil
+}
+
+//resolve prints what's known about a single function.
+func resolve(bp *bananaphone.BananaPhone, name string) error {
+	id, idErr := bp.GetSysID(name)
+	addr, e := bananaphone.ResolveExport("ntdll.dll", name)
+	if e != nil {
+		return e
+	}
+	stub, e := bp.ReadProcessMemory(ntconst.CurrentProcess, addr, stubBytes)
+	if e != nil {
+		return e
+	}
+	fmt.Printf("name:    %s\n", name)
+	if idErr != nil {
+		fmt.Printf("sysid:   (%v)\n", idErr)
+	} else {
+		fmt.Printf("sysid:   %#04x\n", id)
+	}
+	fmt.Printf("address: %#x\n", addr)
+	fmt.Printf("stub:    % x\n", stub)
+	return nil
+}
+
+//hooks prints every in-memory Nt*/Zw* stub that doesn't start with bananaphone.HookCheck.
+func hooks(bp *bananaphone.BananaPhone) error {
+	exports, base, e := syscallExports()
+	if e != nil {
+		return e
+	}
+	checked, hooked := 0, 0
+	for _, ex := range exports {
+		addr := base + uintptr(ex.VirtualAddress)
+		stub, e := bp.ReadProcessMemory(ntconst.CurrentProcess, addr, 8)
+		if e != nil {
+			return e
+		}
+		//not everything called Nt* is a syscall stub. If it doesn't even start with mov r10, rcx or a jmp it's probably a real function.
+		if !bytes.HasPrefix(stub, bananaphone.HookCheck[:3]) && !isJump(stub) {
+			continue
+		}
+		checked++
+		if bytes.HasPrefix(stub, bananaphone.HookCheck) {
+			continue
+		}
+		hooked++
+		fmt.Printf("%-50s %#x  % x\n", ex.Name, addr, stub)
+	}
+	fmt.Printf("%d of %d stubs look hooked\n", hooked, checked)
+	return nil
+}
+
+//report prints the phone's health check.
+func report(bp *bananaphone.BananaPhone) error {
+	r, e := bp.Report()
+	if e != nil {
+		return e
+	}
+	fmt.Print(r)
+	return nil
+}
+
+//gen writes the Go table for the module at path.
+func gen(path string) error {
+	t, e := syscalltable.FromFile(path)
+	if e != nil {
+		return e
+	}
+	w := os.Stdout
+	if *genFile != "" {
+		f, e := os.Create(*genFile)
+		if e != nil {
+			return e
+		}
+		defer f.Close()
+		w = f
+	}
+	return t.WriteGo(w, *genPkg, *genVar)
+}
+
+//isJump returns true if b starts with a jmp rel32 or jmp [rip+rel32].
+func isJump(b []byte) bool {
+	return (len(b) > 0 && b[0] == 0xe9) || bytes.HasPrefix(b, []byte{0xff, 0x25})
+}
@@ -0,0 +1,68 @@
+//mkbananasyscall is a drop in replacement for mkwinsyscall. It reads the same //sys and //sysnb comments, but the generated functions resolve their sysid with a bananaphone (NewSystemBananaPhoneNamed, against the dll named in the comment) and make the call with bananaphone.Syscall rather than syscall.Syscall. Only Nt/Zw functions are syscalls, so only point it at those.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/C-Sto/BananaPhone/internal/mksyscall"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: mkbananasyscall [flags] [path ...]\n")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+var (
+	filename       = flag.String("output", "", "output file name (standard output if omitted)")
+	printTraceFlag = flag.Bool("trace", false, "generate print statement after every syscall")
+	mode           = flag.String("mode", "auto", "Which bananaphone mode to use (default auto, anything not in the following options results in auto) Options: disk,memory,raw")
+	noglobal       = flag.Bool("noglobal", false, "Do not use a global var (embed the bananaphone object into each function)")
+	_              = flag.Bool("systemdll", true, "ignored, dlls are always loaded from the system directory (accepted for mkwinsyscall compatibility)")
+)
+
+func main() {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	flag.Usage = usage
+	flag.Parse()
+	if len(flag.Args()) <= 0 {
+		fmt.Fprintf(os.Stderr, "no files to parse provided\n")
+		usage()
+	}
+
+	src, err := mksyscall.ParseFiles(flag.Args(), mksyscall.Options{
+		Markers:    []string{"//sys", "//sysnb"},
+		Mode:       *mode,
+		Global:     !*noglobal,
+		PrintTrace: *printTraceFlag,
+		Named:      true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := src.Generate(&buf); err != nil {
+		log.Println(buf.String())
+		log.Fatal(err)
+	}
+	data, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Println(buf.String())
+		log.Fatal(err)
+	}
+	if *filename == "" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = ioutil.WriteFile(*filename, data, 0644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
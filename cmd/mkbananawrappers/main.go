@@ -0,0 +1,280 @@
+//mkbananawrappers generates typed Go wrappers for Nt functions from a list of declarations. The input is a Go file of function declarations without bodies (keep it out of the build with an ignore build tag), eg:
+//
+//	func NtClose(handle uintptr) error
+//	func NtWaitForSingleObject(handle uintptr, alertable bool, timeout *int64) (uint32, error)
+//
+//Each generated wrapper resolves its sysid with the bananaphone Default phone the first time it's called, marshals the args into uintptrs, and returns a failed NTSTATUS as a bananaphone.NTStatus error. A leading integer result gets the raw NTSTATUS, for the calls where success values like STATUS_PENDING matter.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: mkbananawrappers [flags] declarations.go\n")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+var (
+	filename = flag.String("output", "", "output file name (standard output if omitted)")
+	pkgname  = flag.String("package", "", "package name of the generated file (defaults to the package of the declarations)")
+)
+
+const bananaImport = "github.com/C-Sto/BananaPhone/pkg/BananaPhone"
+
+func main() {
+	log.SetFlags(0)
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		usage()
+	}
+
+	data, err := generate(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *filename == "" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = ioutil.WriteFile(*filename, data, 0644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+//generate reads the declarations in path and returns the formatted wrapper source.
+func generate(path string) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	pkg := f.Name.Name
+	if *pkgname != "" {
+		pkg = *pkgname
+	}
+	bp := "bananaphone."
+	if pkg == "bananaphone" {
+		bp = ""
+	}
+
+	imports := map[string]string{"unsafe": ""}
+	if bp != "" {
+		imports[bananaImport] = "bananaphone"
+	}
+	for _, imp := range f.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		name := ""
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		imports[p] = name
+	}
+
+	var body bytes.Buffer
+	for _, decl := range f.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		if fd.Body != nil || fd.Recv != nil {
+			return nil, fmt.Errorf("%s: %s should be a plain declaration with no body", fset.Position(fd.Pos()), fd.Name.Name)
+		}
+		w, err := newWrapper(fd, bp)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fset.Position(fd.Pos()), err)
+		}
+		w.write(&body)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by mkbananawrappers; DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	paths := make([]string, 0, len(imports))
+	for p := range imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	//standard library first, then everything else
+	for _, std := range []bool{true, false} {
+		for _, p := range paths {
+			if isStd(p) == std {
+				fmt.Fprintf(&out, "\t%s %q\n", imports[p], p)
+			}
+		}
+		out.WriteString("\n")
+	}
+	out.WriteString(")\n\nvar _ unsafe.Pointer\n")
+	out.Write(body.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated bad source: %v\n%s", err, out.Bytes())
+	}
+	return src, nil
+}
+
+//isStd guesses whether an import path is from the standard library, the same way goimports does - no dot in the first element.
+func isStd(path string) bool {
+	return !strings.Contains(strings.SplitN(path, "/", 2)[0], ".")
+}
+
+//param is a single argument of a wrapper.
+type param struct {
+	name string
+	typ  string
+	kind paramKind
+}
+
+type paramKind int
+
+const (
+	//plain values are converted with uintptr()
+	plainParam paramKind = iota
+	pointerParam
+	unsafePointerParam
+	sliceParam
+	boolParam
+)
+
+//wrapper is a single function to generate.
+type wrapper struct {
+	name      string
+	doc       string
+	params    []param
+	statusTyp string //type of the leading NTSTATUS result, if there is one
+	bp        string
+}
+
+func newWrapper(fd *ast.FuncDecl, bp string) (*wrapper, error) {
+	w := &wrapper{name: fd.Name.Name, bp: bp}
+	if fd.Doc != nil {
+		w.doc = fd.Doc.Text()
+	}
+
+	for i, field := range fd.Type.Params.List {
+		typ := types.ExprString(field.Type)
+		kind := plainParam
+		switch t := field.Type.(type) {
+		case *ast.StarExpr:
+			kind = pointerParam
+		case *ast.ArrayType:
+			if t.Len != nil {
+				return nil, fmt.Errorf("%s: arrays can't be passed to a syscall, use a pointer or slice", typ)
+			}
+			kind = sliceParam
+		case *ast.Ellipsis:
+			return nil, errors.New("variadic params aren't supported")
+		case *ast.Ident:
+			switch t.Name {
+			case "bool":
+				kind = boolParam
+			case "string":
+				return nil, errors.New("Nt functions don't take strings, use *bananaphone.UnicodeString")
+			}
+		}
+		if typ == "unsafe.Pointer" {
+			kind = unsafePointerParam
+		}
+		if len(field.Names) == 0 {
+			w.params = append(w.params, param{name: fmt.Sprintf("arg%d", i), typ: typ, kind: kind})
+		}
+		for _, n := range field.Names {
+			w.params = append(w.params, param{name: n.Name, typ: typ, kind: kind})
+		}
+	}
+
+	var results []string
+	if fd.Type.Results != nil {
+		for _, field := range fd.Type.Results.List {
+			n := len(field.Names)
+			if n == 0 {
+				n = 1
+			}
+			for i := 0; i < n; i++ {
+				results = append(results, types.ExprString(field.Type))
+			}
+		}
+	}
+	switch {
+	case len(results) == 1 && results[0] == "error":
+	case len(results) == 2 && results[1] == "error":
+		w.statusTyp = results[0]
+	default:
+		return nil, errors.New("results must be error, or an integer type for the NTSTATUS and error")
+	}
+	return w, nil
+}
+
+//write writes the wrapper function.
+func (w *wrapper) write(out *bytes.Buffer) {
+	fmt.Fprintln(out)
+	if w.doc != "" {
+		for _, l := range strings.Split(strings.TrimSuffix(w.doc, "\n"), "\n") {
+			fmt.Fprintf(out, "//%s\n", l)
+		}
+	} else {
+		fmt.Fprintf(out, "//%s calls the %s syscall through bananaphone. The sysid is resolved by the Default phone on first use.\n", w.name, w.name)
+	}
+
+	sig := make([]string, len(w.params))
+	for i, p := range w.params {
+		sig[i] = p.name + " " + p.typ
+	}
+	results := "(err error)"
+	if w.statusTyp != "" {
+		results = fmt.Sprintf("(status %s, err error)", w.statusTyp)
+	}
+	fmt.Fprintf(out, "func %s(%s) %s {\n", w.name, strings.Join(sig, ", "), results)
+	fmt.Fprintf(out, "\tsysid, err := %sSysID(%q)\n\tif err != nil {\n\t\treturn\n\t}\n", w.bp, w.name)
+
+	//bananaphone.Syscall isn't special to the compiler like syscall.Syscall, so pin anything we take the address of until it's done
+	for _, p := range w.params {
+		if p.kind == pointerParam || p.kind == unsafePointerParam || p.kind == sliceParam {
+			fmt.Fprintf(out, "\tvar _pin %sPinner\n\tdefer _pin.Unpin()\n", w.bp)
+			break
+		}
+	}
+	args := []string{"sysid"}
+	for i, p := range w.params {
+		switch p.kind {
+		case pointerParam:
+			fmt.Fprintf(out, "\t_pin.Pin(%s)\n", p.name)
+			args = append(args, fmt.Sprintf("uintptr(unsafe.Pointer(%s))", p.name))
+		case unsafePointerParam:
+			fmt.Fprintf(out, "\t_pin.Pin(%s)\n", p.name)
+			args = append(args, fmt.Sprintf("uintptr(%s)", p.name))
+		case sliceParam:
+			tmp := fmt.Sprintf("_p%d", i)
+			fmt.Fprintf(out, "\tvar %s uintptr\n\tif len(%s) > 0 {\n\t\t_pin.Pin(&%s[0])\n\t\t%s = uintptr(unsafe.Pointer(&%s[0]))\n\t}\n", tmp, p.name, p.name, tmp, p.name)
+			args = append(args, tmp)
+		case boolParam:
+			tmp := fmt.Sprintf("_p%d", i)
+			fmt.Fprintf(out, "\tvar %s uintptr\n\tif %s {\n\t\t%s = 1\n\t}\n", tmp, p.name, tmp)
+			args = append(args, tmp)
+		default:
+			args = append(args, fmt.Sprintf("uintptr(%s)", p.name))
+		}
+	}
+	fmt.Fprintf(out, "\tr, _ := %sSyscall(%s)\n", w.bp, strings.Join(args, ", "))
+	if w.statusTyp != "" {
+		fmt.Fprintf(out, "\tstatus = %s(r)\n", w.statusTyp)
+	}
+	fmt.Fprintf(out, "\tif !%sNTStatus(r).IsSuccess() {\n\t\terr = %sNTStatus(r)\n\t}\n\treturn\n}\n", w.bp, w.bp)
+}
@@ -8,6 +8,8 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+
+	"github.com/C-Sto/BananaPhone/internal/mksyscall"
 )
 
 func usage() {
@@ -36,7 +38,12 @@ func main() {
 		usage()
 	}
 
-	src, err := ParseFiles(flag.Args(), *mode, !*noglobal)
+	src, err := mksyscall.ParseFiles(flag.Args(), mksyscall.Options{
+		Markers:    []string{marker},
+		Mode:       *mode,
+		Global:     !*noglobal,
+		PrintTrace: *printTraceFlag,
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -8,12 +8,14 @@ import (
 
 func main() {
 	fmt.Println("modules!")
-	x, y, z := bananaphone.GetModuleLoadedOrder(0)
-	fmt.Printf("%x, %x %+v\n", x, y, z)
-	x, y, z = bananaphone.GetModuleLoadedOrder(1)
-	fmt.Printf("%x, %x %+v\n", x, y, z)
-	x, y, z = bananaphone.GetModuleLoadedOrder(2)
-	fmt.Printf("%x, %x %+v\n", x, y, z)
+	fmt.Println(bananaphone.GetModuleCount(), "modules loaded")
+	for i := 0; i < 3; i++ {
+		x, y, z, e := bananaphone.GetModuleLoadedOrder(i)
+		if e != nil {
+			panic(e)
+		}
+		fmt.Printf("%x, %x %+v\n", x, y, z)
+	}
 
 	fmt.Println("end modules!")
 	fmt.Println(bananaphone.InMemLoads())
@@ -1,8 +1,9 @@
-package main
+package mksyscall
 
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
 	"strings"
 )
 
@@ -21,6 +22,7 @@ type Fn struct {
 	Propermode   string
 	Global       bool
 	Internal     bool //is the function internal?
+	Named        bool //resolve against the named dll with NewSystemBananaPhoneNamed
 }
 
 // extractSection extracts text out of string s starting after start
@@ -57,7 +59,6 @@ func NewFn(s, mode string, global, internal bool) (*Fn, error) {
 	f := &Fn{
 		Rets:       &Rets{}, //todo:
 		src:        s,
-		PrintTrace: *printTraceFlag,
 		Internal:   internal,
 		Propermode: mode,
 		Global:     global,
@@ -196,6 +197,9 @@ func (f *Fn) BananaLoader() string {
 		return `` //no loader, because user indicates they know what they are doing :smirkemoji:
 	}
 	yaboi := ``
+	if f.Named {
+		return f.namedLoader()
+	}
 	if f.Global {
 		yaboi = `if bpGlobal == nil {` + //check if our bp is nill or not (maybe something broke it during  init?)
 			`
@@ -225,6 +229,71 @@ func (f *Fn) BananaLoader() string {
 	return fmt.Sprintf(yaboi, f.GetGlobalVar(), f.DLLFuncName())
 }
 
+//DLLFileName returns the file name of the dll function f is resolved from. Functions that don't name one come from ntdll.
+func (f *Fn) DLLFileName() string {
+	name := f.dllname
+	if name == "" {
+		name = "ntdll"
+	}
+	if filepath.Ext(name) == "" {
+		name += ".dll"
+	}
+	return strings.ToLower(name)
+}
+
+//PhoneVar returns the name of the global phone used for the dll of function f, eg bpNtdll.
+func (f *Fn) PhoneVar() string {
+	name := strings.TrimSuffix(f.DLLFileName(), filepath.Ext(f.DLLFileName()))
+	var sb strings.Builder
+	sb.WriteString("bp")
+	for i, c := range name {
+		switch {
+		case i == 0 && c >= 'a' && c <= 'z':
+			sb.WriteRune(c - 'a' + 'A')
+		case (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9'):
+			sb.WriteRune(c)
+		default:
+			sb.WriteRune('_')
+		}
+	}
+	return sb.String()
+}
+
+//namedPhone returns the expression that creates a phone for the dll of function f.
+func (f *Fn) namedPhone(bpdot string) string {
+	return fmt.Sprintf("%sNewSystemBananaPhoneNamed(%s%s, %q, `C:\\Windows\\system32\\%s`)", bpdot, bpdot, f.Propermode, f.DLLFileName(), f.DLLFileName())
+}
+
+//namedLoader is BananaLoader for Named functions. NewSystemBananaPhoneNamed doesn't give us an error, so a nil phone is all we have to go on.
+func (f *Fn) namedLoader() string {
+	bpdot := "bananaphone."
+	if f.Internal {
+		bpdot = ""
+	}
+	bp := f.PhoneVar()
+	loader := ``
+	if !f.Global {
+		bp = "bp"
+		loader = `bp := ` + f.namedPhone(bpdot) + `
+	`
+	}
+	loader += fmt.Sprintf(`if %s == nil {
+		err = fmt.Errorf("BananaPhone uninitialised: could not load %s")
+		return
+	}
+	`, bp, f.DLLFileName())
+	if !f.Global {
+		loader += `defer bp.Close()
+	`
+	}
+	loader += fmt.Sprintf(`sysid, e := %s.GetSysID("%s")
+	if e != nil {
+		err = e
+		return
+	}`, bp, f.DLLFuncName())
+	return loader
+}
+
 func (f *Fn) GetGlobalVar() string {
 	if f.Global {
 		return "Global"
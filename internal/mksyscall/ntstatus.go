@@ -1,4 +1,4 @@
-package main
+package mksyscall
 
 /*
 https://docs.microsoft.com/en-us/windows-hardware/drivers/kernel/using-ntstatus-values?redirectedfrom=MSDN
@@ -1,4 +1,4 @@
-package main
+package mksyscall
 
 //this file is here so that I don't lose track of what the template used to look like. Template is probably not the best option for this tbh.
 
@@ -1,4 +1,4 @@
-package main
+package mksyscall
 
 import (
 	"bufio"
@@ -14,10 +14,24 @@ import (
 	"text/template"
 )
 
+//Options controls what ParseFiles looks for, and how the generated code resolves sysids.
+type Options struct {
+	//Markers are the comments that start a function definition, eg "//dsys".
+	Markers []string
+	//Mode is the bananaphone mode to use: auto, memory, disk or raw.
+	Mode string
+	//Global uses a package level bananaphone rather than making one per call.
+	Global bool
+	//PrintTrace generates a print statement after every syscall.
+	PrintTrace bool
+	//Named resolves each function against the dll named in its definition (eg "= ntdll.NtClose") with NewSystemBananaPhoneNamed, rather than always using ntdll with NewBananaPhone.
+	Named bool
+}
+
 // ParseFiles parses files listed in fs and extracts all syscall
-// functions listed in dsys comments. It returns a files
+// functions listed in marker comments. It returns a files
 // and functions collection *Source if successful.
-func ParseFiles(files []string, mode string, global bool) (*Source, error) {
+func ParseFiles(files []string, opts Options) (*Source, error) {
 	src := &Source{
 		//Funcs: make([]*Fn, 0),
 		Files: make([]string, 0),
@@ -25,8 +39,11 @@ func ParseFiles(files []string, mode string, global bool) (*Source, error) {
 			"unsafe",
 		},
 		ExternalImports: make([]string, 0),
-		Mode:            setmode(mode),
-		Global:          global,
+		Mode:            setmode(opts.Mode),
+		Global:          opts.Global,
+		Markers:         opts.Markers,
+		PrintTrace:      opts.PrintTrace,
+		Named:           opts.Named,
 	}
 
 	for _, file := range files {
@@ -46,6 +63,9 @@ type Source struct {
 	PackageName     string
 	Mode            string
 	Global          bool
+	Markers         []string
+	PrintTrace      bool
+	Named           bool
 }
 
 //Import adds an import to the current source (this should never really be called?)
@@ -95,27 +115,24 @@ func (src *Source) ParseFile(path string) error {
 				continue
 			}
 		*/
-		if !strings.HasPrefix(t, marker) { //not what we are lookin for fam!
+		marker := src.marker(t)
+		if marker == "" { //not what we are lookin for fam!
 			continue
 		}
 
-		t = t[len(marker):] //skip marker
-
-		//check for space/tab after the //dsys marker. Just incase someone wrote //dsysnuts or something I guess? idk
-		if !(t[0] == ' ' || t[0] == '\t') {
-			continue
-		}
-		t = strings.TrimSpace(t)
+		t = strings.TrimSpace(t[len(marker):]) //skip marker
 
 		//what's left should be a function definition. Let's parse that badboi!
 		std, err := src.IsStdRepo(path)
 		if err != nil {
 			return err
 		}
-		f, err := NewFn(t, setmode(*mode), src.Global, std)
+		f, err := NewFn(t, src.Mode, src.Global, std)
 		if err != nil {
 			return err
 		}
+		f.PrintTrace = src.PrintTrace
+		f.Named = src.Named
 		src.Funcs = append(src.Funcs, f)
 
 	}
@@ -140,6 +157,20 @@ func (src *Source) ParseFile(path string) error {
 	return nil
 }
 
+//marker returns the marker that line starts with, or an empty string if there isn't one.
+func (src *Source) marker(line string) string {
+	for _, m := range src.Markers {
+		if !strings.HasPrefix(line, m) {
+			continue
+		}
+		//check for space/tab after the marker. Just incase someone wrote //dsysnuts or something I guess? idk
+		if rest := line[len(m):]; len(rest) > 0 && (rest[0] == ' ' || rest[0] == '\t') {
+			return m
+		}
+	}
+	return ""
+}
+
 // Generate output source file
 func (src *Source) Generate(w io.Writer) error {
 
@@ -220,6 +251,9 @@ func (src *Source) VarBlock() string {
 	if src.Mode == "raw" || !src.Global {
 		return ``
 	}
+	if src.Named {
+		return src.namedVarBlock()
+	}
 
 	tpl := `var (
 		bpGlobal, bperr = %sNewBananaPhone(%s%s)
@@ -227,6 +261,22 @@ func (src *Source) VarBlock() string {
 	return fmt.Sprintf(tpl, src.BananaPhonedot(), src.BananaPhonedot(), src.Mode)
 }
 
+//namedVarBlock returns a var block with a phone for each dll referenced by the functions.
+func (src *Source) namedVarBlock() string {
+	seen := map[string]bool{}
+	var sb strings.Builder
+	sb.WriteString("var (\n")
+	for _, f := range src.Funcs {
+		if seen[f.PhoneVar()] {
+			continue
+		}
+		seen[f.PhoneVar()] = true
+		fmt.Fprintf(&sb, "\t%s = %s\n", f.PhoneVar(), f.namedPhone(src.BananaPhonedot()))
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
 func setmode(mode string) string {
 	switch mode {
 	case "memory":
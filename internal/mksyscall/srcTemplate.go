@@ -1,4 +1,4 @@
-package main
+package mksyscall
 
 const srcTemplate = `
 {{define "main"}}// Code generated by 'go generate'; DO NOT EDIT.
@@ -0,0 +1,93 @@
+package bananaphone
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+//apiSetSchemaVersion is the only ApiSetMap (API_SET_NAMESPACE) layout we understand, used from Windows 10 on.
+const apiSetSchemaVersion = 6
+
+//sizes of API_SET_NAMESPACE, API_SET_NAMESPACE_ENTRY and API_SET_VALUE_ENTRY in the version 6 schema.
+const (
+	apiSetHeaderSize = 0x1c
+	apiSetEntrySize  = 0x18
+	apiSetValueSize  = 0x14
+)
+
+//isAPISet returns true if a module name is an api set rather than a real dll. The loader goes on the prefix too.
+func isAPISet(name string) bool {
+	n := strings.ToLower(name)
+	return strings.HasPrefix(n, "api-") || strings.HasPrefix(n, "ext-")
+}
+
+//apiSetHost looks an api set up in a version 6 ApiSetMap, returning the dll that hosts it. If the set has a host just for importer (a base name, eg kernel32.dll) that's used, otherwise the default one is.
+func apiSetHost(ns []byte, name, importer string) (string, error) {
+	le := binary.LittleEndian
+	if len(ns) < apiSetHeaderSize {
+		return "", fmt.Errorf("%w: %s (ApiSetMap is truncated)", ErrAPISetNotFound, name)
+	}
+	if v := le.Uint32(ns); v != apiSetSchemaVersion {
+		return "", fmt.Errorf("%w: %s (ApiSetMap schema version %d isn't supported)", ErrAPISetNotFound, name, v)
+	}
+	count, entryOff := le.Uint32(ns[0xc:]), le.Uint32(ns[0x10:])
+
+	//entries are matched on the name up to the last hyphen, so any patch version (the -0 in api-ms-win-core-synch-l1-2-0) will do
+	key := strings.TrimSuffix(strings.ToLower(name), ".dll")
+	if i := strings.LastIndex(key, "-"); i >= 0 {
+		key = key[:i]
+	}
+	for i := uint32(0); i < count; i++ {
+		ent, ok := apiSetBytes(ns, uint64(entryOff)+uint64(i)*apiSetEntrySize, apiSetEntrySize)
+		if !ok {
+			return "", fmt.Errorf("%w: %s (ApiSetMap entry %d is out of bounds)", ErrAPISetNotFound, name, i)
+		}
+		//NameOffset, then HashedLength rather than NameLength
+		if n, ok := apiSetString(ns, le.Uint32(ent[4:]), le.Uint32(ent[12:])); !ok || !strings.EqualFold(n, key) {
+			continue
+		}
+		valueOff, valueCount := le.Uint32(ent[16:]), le.Uint32(ent[20:])
+		host := ""
+		for j := uint32(0); j < valueCount; j++ {
+			val, ok := apiSetBytes(ns, uint64(valueOff)+uint64(j)*apiSetValueSize, apiSetValueSize)
+			if !ok {
+				break
+			}
+			importName, _ := apiSetString(ns, le.Uint32(val[4:]), le.Uint32(val[8:]))
+			value, _ := apiSetString(ns, le.Uint32(val[12:]), le.Uint32(val[16:]))
+			if importName == "" && host == "" {
+				host = value
+			} else if importName != "" && strings.EqualFold(importName, importer) && value != "" {
+				return value, nil
+			}
+		}
+		if host == "" {
+			return "", fmt.Errorf("%w: %s has no host module", ErrAPISetNotFound, name)
+		}
+		return host, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrAPISetNotFound, name)
+}
+
+//apiSetBytes returns n bytes of the ApiSetMap at off, if they're all inside it.
+func apiSetBytes(ns []byte, off, n uint64) ([]byte, bool) {
+	if off+n > uint64(len(ns)) {
+		return nil, false
+	}
+	return ns[off : off+n], true
+}
+
+//apiSetString decodes a UTF-16 string in the ApiSetMap. Lengths are in bytes.
+func apiSetString(ns []byte, off, length uint32) (string, bool) {
+	b, ok := apiSetBytes(ns, uint64(off), uint64(length))
+	if !ok {
+		return "", false
+	}
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(u)), true
+}
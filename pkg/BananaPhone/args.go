@@ -0,0 +1,81 @@
+package bananaphone
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unsafe"
+)
+
+//Arg is a syscall argument made from a Go value. The backing allocation is pinned (see Pinner) and held by the package until Release is called, so the uintptr from Ptr stays valid however long the syscall (or the kernel, for async calls) holds on to it - even if you don't keep the Arg around yourself. Call Release once nothing is going to use the pointer any more.
+type Arg struct {
+	ptr uintptr
+	pin Pinner
+}
+
+var (
+	liveArgsMu sync.Mutex
+	liveArgs   = map[*Arg]struct{}{}
+)
+
+//newArg registers a new Arg, pinning the value pointer (which ptr points into).
+func newArg(ptr uintptr, pointer interface{}) *Arg {
+	a := &Arg{ptr: ptr}
+	a.pin.Pin(pointer)
+	liveArgsMu.Lock()
+	liveArgs[a] = struct{}{}
+	liveArgsMu.Unlock()
+	return a
+}
+
+//Ptr returns the argument as a uintptr, ready to hand to Syscall. It's 0 after Release.
+func (a *Arg) Ptr() uintptr {
+	return a.ptr
+}
+
+//Release lets the backing allocation go. Calling it more than once is fine.
+func (a *Arg) Release() {
+	liveArgsMu.Lock()
+	delete(liveArgs, a)
+	liveArgsMu.Unlock()
+	a.pin.Unpin()
+	a.ptr = 0
+}
+
+//UTF16PtrFromString converts s to a null terminated UTF-16 string, the same as windows.UTF16PtrFromString. Strings containing a null byte are an error.
+func UTF16PtrFromString(s string) (*Arg, error) {
+	if strings.IndexByte(s, 0) != -1 {
+		return nil, errors.New("string contains a null byte")
+	}
+	buf := utf16.Encode([]rune(s + "\x00"))
+	return newArg(uintptr(unsafe.Pointer(&buf[0])), &buf[0]), nil
+}
+
+//BytesPtr returns a pointer to the first byte of b, or 0 for an empty slice.
+func BytesPtr(b []byte) *Arg {
+	if len(b) == 0 {
+		return newArg(0, nil)
+	}
+	return newArg(uintptr(unsafe.Pointer(&b[0])), &b[0])
+}
+
+//StructPtr takes a pointer to a value (usually a struct the syscall fills in or reads from, eg &ObjectAttributes{}) and returns it as an Arg. Anything other than a non-nil pointer is an error.
+func StructPtr(v interface{}) (*Arg, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil, errors.New("StructPtr needs a non-nil pointer")
+	}
+	return newArg(rv.Pointer(), v), nil
+}
+
+//SyscallPinned is Syscall, with each of the pointers in pinned pinned for the duration of the call. Use it when the args were worked out from Go pointers before the call, rather than converted inline in the Syscall expression. If you're managing a Pinner (or Args) yourself, just use Syscall.
+func SyscallPinned(callid uint16, pinned []interface{}, argh ...uintptr) (errcode uint32, err error) {
+	var p Pinner
+	defer p.Unpin()
+	for _, ptr := range pinned {
+		p.Pin(ptr)
+	}
+	return Syscall(callid, argh...)
+}
@@ -1,3 +1,6 @@
+//go:build windows && amd64
+// +build windows,amd64
+
 
 //func GetPEB() uintptr
 TEXT ·GetPEB(SB), $0-8
@@ -5,6 +8,13 @@ TEXT ·GetPEB(SB), $0-8
      MOVQ	AX, ret+0(FP)
      RET
 
+//func GetTEB() uintptr
+TEXT ·GetTEB(SB), $0-8
+     //NT_TIB->Self
+     MOVQ 	0x30(GS), AX
+     MOVQ	AX, ret+0(FP)
+     RET
+
 //func GetNtdllStart() uintptr
 TEXT ·GetNtdllStart(SB), $0-16
 	//All operations push values into AX
@@ -63,8 +73,8 @@ endloop:
 	//SYSCALL
 	RET 
 
-//func GetModuleLoadedOrderPtr(i int) *LdrTableThing
-TEXT ·GetModuleLoadedOrderPtr(SB), $0-16
+//func getModuleLoadedOrderPtr(i int) *LdrTableThing
+TEXT ·getModuleLoadedOrderPtr(SB), $0-16
 	//All operations push values into AX
 	//PEB
 	MOVQ 0x60(GS), AX
@@ -92,107 +102,117 @@ endloop:
 
 //based on https://golang.org/src/runtime/sys_windows_amd64.s
 #define maxargs 16
-//func Syscall(callid uint16, argh ...uintptr) (uint32, error)
-TEXT ·bpSyscall(SB), $0-56
-	XORQ AX,AX
+//func bpSyscall(callid uint16, argh ...uintptr) (errcode uint32)
+//The args are laid out in our frame the way a stub sees them after being CALLed: a slot where the return address would be, the first 4 args (the shadow space), then the rest. Letting the assembler reserve the frame (maxargs+1 slots), rather than moving SP about by hand, means the prologue checks there's room and grows the goroutine stack first if there isn't - the runtime fixes up argh if that moves it. Nothing after the prologue can be preempted or grow the stack, and the only registers touched are ones ABI0 lets us clobber.
+TEXT ·bpSyscall(SB), $136-36
+	XORQ AX, AX
 	MOVW callid+0(FP), AX
-	PUSHQ CX
 	//put variadic size into CX
-	MOVQ argh_len+16(FP),CX
+	MOVQ argh_len+16(FP), CX
 	//put variadic pointer into SI
-	MOVQ argh_base+8(FP),SI
+	MOVQ argh_base+8(FP), SI
 	// SetLastError(0).
 	MOVQ	0x30(GS), DI
 	MOVL	$0, 0x68(DI)
-	SUBQ	$(maxargs*8), SP	// room for args
-	// Fast version, do not store args on the stack.
-	CMPL	CX, $4
-	JLE	loadregs
-	// Check we have enough room for args.
-	CMPL	CX, $maxargs
+	// Check we have enough room for args. Length has been checked on the go side.
+	CMPQ	CX, $maxargs
 	JLE	2(PC)
 	INT	$3			// not enough room -> crash
-	// Copy args to the stack.
-	MOVQ	SP, DI
+	// Copy args to the stack, after the return address slot. Only len(argh) are copied, so we never read past the end of the slice.
+	LEAQ	8(SP), DI
 	CLD
 	REP; MOVSQ
-	MOVQ	SP, SI
-loadregs:
-	//move the stack pointer????? why????
-	SUBQ	$8, SP
 	// Load first 4 args into correspondent registers.
-	MOVQ	0(SI), CX
-	MOVQ	8(SI), DX
-	MOVQ	16(SI), R8
-	MOVQ	24(SI), R9
-	// Floating point arguments are passed in the XMM
-	// registers. Set them here in case any of the arguments
-	// are floating point values. For details see
-	//	https://msdn.microsoft.com/en-us/library/zthk2dkh.aspx
-	MOVQ	CX, X0
-	MOVQ	DX, X1
-	MOVQ	R8, X2
-	MOVQ	R9, X3
-	//MOVW callid+0(FP), AX
-	MOVQ CX, R10
+	MOVQ	8(SP), CX
+	MOVQ	16(SP), DX
+	MOVQ	24(SP), R8
+	MOVQ	32(SP), R9
+	MOVQ	CX, R10
 	SYSCALL
-	ADDQ	$((maxargs+1)*8), SP
 	// Return result.
-	POPQ	CX
 	MOVL	AX, errcode+32(FP)
 	RET
 
 //func bpRecycledGateSyscall(callid uint16, jump uintptr, argh ...uintptr) (errcode uint32)
-TEXT ·bpRecycledGateSyscall(SB),$0-56
-    PUSHQ CX
-	XORQ AX,AX
+//Same as bpSyscall, except the syscall; ret we CALL pushes a real return address, so the args start at the bottom of the frame. BX holds the jump rather than R15, which the linker clobbers when dynamic linking.
+TEXT ·bpRecycledGateSyscall(SB), $128-44
+	XORQ AX, AX
 	MOVW callid+0(FP), AX
-    XORQ R15,R15
-    MOVQ jump+8(FP), R15
+	MOVQ jump+8(FP), BX
 	//put variadic size into CX
-	MOVQ argh_len+24(FP),CX
+	MOVQ argh_len+24(FP), CX
 	//put variadic pointer into SI
-	MOVQ argh_base+16(FP),SI
+	MOVQ argh_base+16(FP), SI
 	// SetLastError(0).
 	MOVQ	0x30(GS), DI
 	MOVL	$0, 0x68(DI)
-	SUBQ	$(maxargs*8), SP	// room for args
-
-	// Fast version, do not store args on the stack.
-	CMPL	CX, $4
-	JLE	loadregs
-	// Check we have enough room for args.
-	CMPL	CX, $maxargs
+	// Check we have enough room for args. Length has been checked on the go side.
+	CMPQ	CX, $maxargs
 	JLE	2(PC)
 	INT	$3			// not enough room -> crash
 	// Copy args to the stack.
 	MOVQ	SP, DI
 	CLD
 	REP; MOVSQ
-	MOVQ	SP, SI
-loadregs:
-    //move the stack pointer????? why????
-	//SUBQ	$8, SP
+	// Load first 4 args into correspondent registers.
+	MOVQ	0(SP), CX
+	MOVQ	8(SP), DX
+	MOVQ	16(SP), R8
+	MOVQ	24(SP), R9
+	MOVQ	CX, R10
+	//syscall;ret
+	CALL	BX
+	// Return result.
+	MOVL	AX, errcode+40(FP)
+	RET
 
+//func bpCallFunction(addr, stackTop, stackLimit uintptr, argh ...uintptr) (ret uintptr)
+//Calls addr with the win64 ABI on the native stack [stackLimit, stackTop) rather than the goroutine stack. The TEB's StackBase/StackLimit (NT_TIB, 0x08 and 0x10) are pointed at it for the call so __chkstk and the unwinder see the stack they expect, then put back. R12-R14 are callee-saved in the win64 ABI, so they carry the old SP and TEB limits over the call. Everything is read out of our args before SP moves, since FP is really SP relative.
+TEXT ·bpCallFunction(SB), $0-56
+	MOVQ addr+0(FP), AX
+	MOVQ stackTop+8(FP), BX
+	MOVQ stackLimit+16(FP), DX
+	//put variadic pointer into SI
+	MOVQ argh_base+24(FP), SI
+	//put variadic size into CX
+	MOVQ argh_len+32(FP), CX
+	// Check we have enough room for args. Length has been checked on the go side.
+	CMPQ	CX, $maxargs
+	JLE	2(PC)
+	INT	$3			// not enough room -> crash
+	// Swap the TEB stack limits over to the native stack.
+	MOVQ	0x30(GS), DI
+	MOVQ	0x08(DI), R13
+	MOVQ	0x10(DI), R14
+	MOVQ	BX, 0x08(DI)
+	MOVQ	DX, 0x10(DI)
+	// Switch stacks, 16 byte aligned as the win64 ABI requires, with room for the args (including the 4 slot shadow space).
+	MOVQ	SP, R12
+	MOVQ	BX, SP
+	ANDQ	$~15, SP
+	SUBQ	$(maxargs*8), SP
+	// Copy args to the stack.
+	MOVQ	SP, DI
+	CLD
+	REP; MOVSQ
 	// Load first 4 args into correspondent registers.
-	MOVQ	0(SI), CX
-	MOVQ	8(SI), DX
-	MOVQ	16(SI), R8
-	MOVQ	24(SI), R9
+	MOVQ	0(SP), CX
+	MOVQ	8(SP), DX
+	MOVQ	16(SP), R8
+	MOVQ	24(SP), R9
 	// Floating point arguments are passed in the XMM
 	// registers. Set them here in case any of the arguments
-	// are floating point values. For details see
-	//	https://msdn.microsoft.com/en-us/library/zthk2dkh.aspx
+	// are floating point values.
 	MOVQ	CX, X0
 	MOVQ	DX, X1
 	MOVQ	R8, X2
 	MOVQ	R9, X3
-	MOVQ CX, R10
-    //syscall;ret
-    CALL R15
-    ADDQ	$((maxargs)*8), SP
-    // Return result.
-    POPQ	CX
-    MOVL	AX, errcode+40(FP)
-    RET
-
+	CALL	AX
+	// Back to the goroutine stack, and put the TEB limits back.
+	MOVQ	R12, SP
+	MOVQ	0x30(GS), DI
+	MOVQ	R13, 0x08(DI)
+	MOVQ	R14, 0x10(DI)
+	// Return result.
+	MOVQ	AX, ret+48(FP)
+	RET
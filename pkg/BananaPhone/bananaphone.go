@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/Binject/debug/pe"
-	"github.com/awgh/rawreader"
 )
 
 //PhoneMode determines the way a bananaphone will resolve sysids
@@ -24,11 +24,43 @@ const (
 	HalosGateBananaPhoneMode
 )
 
+//SyscallResolver is the part of a BananaPhone that code making syscalls actually uses. Take one of these instead of a *BananaPhone and you can swap in a MockResolver to test your logic without a Windows box.
+type SyscallResolver interface {
+	GetSysID(funcname string) (uint16, error)
+	GetFuncPtr(funcname string) (uint64, error)
+	NewProc(funcname string) BananaProcedure
+	Syscall(sysid uint16, argh ...uintptr) (errcode uint32, err error)
+}
+
+var _ SyscallResolver = (*BananaPhone)(nil)
+
 //BananaPhone will resolve SysID's used for syscalls while making minimal API calls. These ID's can be used for functions like NtAllocateVirtualMemory as defined in functions.go.
 type BananaPhone struct {
-	banana *pe.File
-	mode   PhoneMode
-	memloc uintptr
+	banana   *pe.File
+	mode     PhoneMode
+	memloc   uintptr
+	name     string
+	diskpath string
+	stub     stubFormat
+	//overrides are set at creation and never change, so they don't need the lock
+	overrides map[string]uint16
+	feed      SysIDFeed //set at creation like overrides, only used when resolving fails
+	cache     *persistentCache
+	exec      *Executor
+
+	//mu guards everything below, as well as swapping out banana
+	mu       sync.Mutex
+	exports  []pe.Export
+	byName   map[string]pe.Export
+	ssnCache map[string]uint16
+	//provenance is how each sysid in ssnCache was resolved
+	provenance map[string]Resolution
+	text       []byte
+	textRVA    uint32
+	fellBack   bool       //Auto mode has switched banana over to the disk copy
+	external   bool       //banana was handed to NewBananaPhoneFromPE, so there's nothing to (re)load
+	hard       *hardTable //set by Harden, replaces ssnCache (and text) while it's in effect
+	cacheErr   error      //the last failed write of the persistent cache, returned by Close
 }
 
 //NewBananaPhone creates a new instance of a bananaphone with behaviour as defined by the input value. Use AutoBananaPhoneMode if you're not sure.
@@ -39,13 +71,13 @@ Possible values:
 	- AutoBananaPhoneMode
 	- HalosGateBananaPhoneMode
 */
-func NewBananaPhone(t PhoneMode) (*BananaPhone, error) {
-	return NewBananaPhoneNamed(t, "ntdll.dll", `C:\Windows\system32\ntdll.dll`)
+func NewBananaPhone(t PhoneMode, opts ...Option) (*BananaPhone, error) {
+	return NewBananaPhoneNamed(t, "ntdll.dll", `C:\Windows\system32\ntdll.dll`, opts...)
 }
 
 //NewSystemBananaPhoneNamed is literally just an un-error handled passthrough for NewBananaPhoneNamed to easily work with mkwinsyscall. The ptr might be nil, who knows! lol! yolo!
-func NewSystemBananaPhoneNamed(t PhoneMode, name, diskpath string) *BananaPhone {
-	r, _ := NewBananaPhoneNamed(t, name, diskpath)
+func NewSystemBananaPhoneNamed(t PhoneMode, name, diskpath string, opts ...Option) *BananaPhone {
+	r, _ := NewBananaPhoneNamed(t, name, diskpath, opts...)
 	return r
 }
 
@@ -57,11 +89,62 @@ Possible values:
 	- AutoBananaPhoneMode
 	- HalosGateBananaPhoneMode
 */
-func NewBananaPhoneNamed(t PhoneMode, name, diskpath string) (*BananaPhone, error) {
+func NewBananaPhoneNamed(t PhoneMode, name, diskpath string, opts ...Option) (*BananaPhone, error) {
+	major, _, build := GetWindowsBuild()
+	var bp = &BananaPhone{
+		mode:     t,
+		name:     name,
+		diskpath: diskpath,
+		stub:     stubFormatForBuild(major, build),
+	}
+	for _, opt := range opts {
+		opt(bp)
+	}
+	if bp.cache != nil && bp.cache.err != nil {
+		return nil, fmt.Errorf("persistent cache: %w", bp.cache.err)
+	}
+	e := bp.load()
+	if bp.banana == nil && e != nil {
+		return nil, e
+	}
+	bp.readPersistentCache()
+	return bp, e
+}
+
+//NewBananaPhoneFromPE creates a bananaphone that resolves against a module that has already been parsed, eg by a custom loader or other Binject tooling, rather than finding one itself. base is where the module is mapped, used by GetFuncPtr and friends - pass 0 if it isn't mapped anywhere and only sysids are wanted. The phone resolves like HalosGateBananaPhoneMode, never touching the PEB or disk, and Refresh only throws away the cached values. The phone takes ownership of p, it's closed by Close.
+func NewBananaPhoneFromPE(p *pe.File, base uintptr, opts ...Option) (*BananaPhone, error) {
+	if p == nil {
+		return nil, errors.New("nil pe.File")
+	}
+	if e := checkSections(p); e != nil {
+		return nil, e
+	}
+	major, _, build := GetWindowsBuild()
+	var bp = &BananaPhone{
+		banana:   p,
+		mode:     HalosGateBananaPhoneMode,
+		memloc:   base,
+		stub:     stubFormatForBuild(major, build),
+		external: true,
+	}
+	for _, opt := range opts {
+		opt(bp)
+	}
+	if bp.cache != nil && bp.cache.err != nil {
+		return nil, fmt.Errorf("persistent cache: %w", bp.cache.err)
+	}
+	bp.readPersistentCache()
+	return bp, nil
+}
+
+//load (re)locates the module the phone was created for and parses it, according to the phone's mode.
+func (b *BananaPhone) load() error {
+	if b.external {
+		return nil
+	}
 	var p *pe.File
 	var e error
-	var bp = &BananaPhone{}
-	switch t {
+	switch b.mode {
 	case HalosGateBananaPhoneMode:
 		fallthrough
 	case AutoBananaPhoneMode:
@@ -69,46 +152,254 @@ func NewBananaPhoneNamed(t PhoneMode, name, diskpath string) (*BananaPhone, erro
 	case MemoryBananaPhoneMode:
 		loads, err := InMemLoads()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		found := false
 		for k, load := range loads { //shout out to Frank Reynolds
-			if strings.EqualFold(k, diskpath) || strings.EqualFold(name, filepath.Base(k)) {
-				rr := rawreader.New(uintptr(load.BaseAddr), int(load.Size))
-				p, e = pe.NewFileFromMemory(rr)
-				bp.memloc = uintptr(load.BaseAddr)
+			if strings.EqualFold(k, b.diskpath) || strings.EqualFold(b.name, filepath.Base(k)) {
+				p, e = parsePE(uintptr(load.BaseAddr), uintptr(load.Size))
+				if e != nil {
+					e = fmt.Errorf("parsing in-memory %s: %w", k, e)
+				}
+				b.memloc = uintptr(load.BaseAddr)
 				found = true
 				break
 			}
 		}
 		if !found {
-			return nil, fmt.Errorf("module not found, bad times (%s %s)", diskpath, filepath.Base(diskpath))
+			return fmt.Errorf("%w, bad times (%s %s)", ErrModuleNotFound, b.diskpath, filepath.Base(b.diskpath))
 		}
 	case DiskBananaPhoneMode:
-		p, e = pe.Open(diskpath)
+		b.memloc = 0 //looked up when it's needed, see moduleBase
+		p, e = openPE(b.diskpath)
+		if e != nil {
+			e = fmt.Errorf("opening %s: %w", b.diskpath, e)
+		}
 	}
-	bp.banana = p
-	bp.mode = t
-	return bp, e
+	if p != nil {
+		if b.banana != nil {
+			b.banana.Close() //disk loaded files hold a handle open
+		}
+		b.banana = p
+		b.fellBack = false
+	}
+	if e != nil && b.mode == AutoBananaPhoneMode && errors.Is(e, ErrMalformedImage) {
+		return b.fallBackToDisk(b.name, e)
+	}
+	return e
+}
+
+//Refresh re-reads the PEB module list and re-parses the module this phone resolves against. Use this if the module was loaded (or re-mapped) after the phone was created, or to go back to the in-memory copy after Auto mode has fallen back to disk. Cached values are thrown away.
+func (b *BananaPhone) Refresh() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.invalidateCache()
+	e := b.load()
+	b.readPersistentCache()
+	return e
+}
+
+//Close releases everything the phone holds: the file handle kept open in disk mode (or after Auto mode has fallen back to disk), and the cached exports, sysids and section snapshot. The section snapshot is zeroed before being released so a clean copy of the module isn't left lying around in the heap. If writing the persistent cache failed at some point and closing the file didn't, that error is returned. The phone can't be used after it's closed.
+func (b *BananaPhone) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.unharden(false)
+	for i := range b.text {
+		b.text[i] = 0
+	}
+	b.invalidateCache()
+	e := b.cacheErr
+	if b.banana != nil {
+		if e2 := b.banana.Close(); e2 != nil {
+			e = e2
+		}
+		b.banana = nil
+	}
+	return e
+}
+
+//InvalidateCache throws away the cached export table and resolved sysids, so they are parsed/resolved again on next use.
+func (b *BananaPhone) InvalidateCache() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.invalidateCache()
+}
+
+func (b *BananaPhone) invalidateCache() {
+	b.unharden(false)
+	b.invalidateExports()
+	b.ssnCache = nil
+	b.provenance = nil
+}
+
+//invalidateExports throws away the parsed export table, for when the underlying image changes.
+func (b *BananaPhone) invalidateExports() {
+	b.exports = nil
+	b.byName = nil
+	b.text = nil
+	b.textRVA = 0
 }
 
-//GetFuncPtr returns a pointer to the function (Virtual Address)
+//getExports returns the export table of the module, parsing it on first use. Callers must hold b.mu.
+func (b *BananaPhone) getExports() ([]pe.Export, error) {
+	if b.exports != nil {
+		return b.exports, nil
+	}
+	if b.banana == nil {
+		return nil, errors.New("bananaphone has been closed")
+	}
+	ex, e := peExports(b.banana)
+	if e != nil {
+		return nil, fmt.Errorf("reading exports of %s: %w", b.name, e)
+	}
+	b.byName = make(map[string]pe.Export, len(ex))
+	for _, exp := range ex {
+		if exp.Name != "" {
+			b.byName[exp.Name] = exp
+		}
+	}
+	b.exports = ex
+	return ex, nil
+}
+
+//sectionData returns a snapshot of the section containing rva (ie, .text) along with the RVA the section starts at. The snapshot is taken once and cached, rather than copying the whole image per lookup. Callers must hold b.mu.
+func (b *BananaPhone) sectionData(rva uint32) ([]byte, uint32, error) {
+	if b.text != nil && rva >= b.textRVA && rva < b.textRVA+uint32(len(b.text)) {
+		return b.text, b.textRVA, nil
+	}
+	s := sectionForRVA(b.banana, rva)
+	if s == nil {
+		return nil, 0, fmt.Errorf("rva %x is not in any section", rva)
+	}
+	d, e := s.Data()
+	if e != nil {
+		return nil, 0, e
+	}
+	b.text, b.textRVA = d, s.VirtualAddress
+	return b.text, b.textRVA, nil
+}
+
+//cachedSysID returns a previously resolved sysid. Callers must hold b.mu.
+func (b *BananaPhone) cachedSysID(key string) (uint16, bool) {
+	if b.hard != nil {
+		return b.hard.lookup(key)
+	}
+	id, ok := b.ssnCache[key]
+	return id, ok
+}
+
+//storeResolution caches a resolved sysid along with where it came from. Callers must hold b.mu.
+func (b *BananaPhone) storeResolution(key string, r Resolution) {
+	b.storeSysID(key, r.SysID)
+	if b.provenance == nil {
+		b.provenance = make(map[string]Resolution)
+	}
+	b.provenance[key] = r
+}
+
+//cachedResolution returns how the cached sysid id for key was resolved. Callers must hold b.mu.
+func (b *BananaPhone) cachedResolution(key string, id uint16) Resolution {
+	if r, ok := b.provenance[key]; ok {
+		return r
+	}
+	return Resolution{SysID: id, Source: b.source()}
+}
+
+//storeSysID caches a resolved sysid, unless the phone is hardened. Callers must hold b.mu.
+func (b *BananaPhone) storeSysID(key string, id uint16) {
+	if b.hard != nil {
+		return
+	}
+	if b.ssnCache == nil {
+		b.ssnCache = make(map[string]uint16)
+	}
+	b.ssnCache[key] = id
+}
+
+//ordKey is the cache key used for sysids resolved by ordinal.
+func ordKey(ordinal uint32) string {
+	return fmt.Sprintf("#%d", ordinal)
+}
+
+//GetFuncPtr returns a pointer to the function (Virtual Address). In disk mode the RVA comes from the disk copy and is rebased onto the copy of the module loaded in this process, which is found through the PEB - if the module isn't loaded there's no address to give, and an ErrModuleNotFound error is returned.
 func (b *BananaPhone) GetFuncPtr(funcname string) (uint64, error) {
-	exports, err := b.banana.Exports()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	exports, err := b.getExports()
 	if err != nil {
 		return 0, err
 	}
-	for _, ex := range exports {
-		if strings.EqualFold(funcname, ex.Name) {
-			return uint64(b.memloc) + uint64(ex.VirtualAddress), nil
+	ex, ok := b.byName[funcname]
+	if !ok {
+		for _, e := range exports {
+			if strings.EqualFold(funcname, e.Name) {
+				ex, ok = e, true
+				break
+			}
+		}
+	}
+	if ok {
+		base, err := b.moduleBase()
+		if err != nil {
+			return 0, err
 		}
+		return uint64(base) + uint64(ex.VirtualAddress), nil
+	}
+	return 0, fmt.Errorf("%w: %s", ErrFunctionNotFound, funcname)
+}
+
+//IsSyscall reports whether the named export is a syscall stub, hooked or not. Some Nt* exports (eg NtdllDefWindowProc_A) are regular functions, as is everything that isn't Nt*/Zw*. GetSysID returns ErrNotASyscall for these, rather than treating them as hooked.
+func (b *BananaPhone) IsSyscall(funcname string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, e := b.getExports(); e != nil {
+		return false, e
+	}
+	ex, ok := b.byName[funcname]
+	if !ok {
+		return false, fmt.Errorf("%w: %s", ErrFunctionNotFound, funcname)
+	}
+	return b.isSyscall(ex.VirtualAddress)
+}
+
+//isSyscall checks the export at rva with stubFormat.isStub. Callers must hold b.mu.
+func (b *BananaPhone) isSyscall(rva uint32) (bool, error) {
+	buff, e := readRVA(b.banana, rva, int(b.stub.size))
+	if e != nil {
+		return false, e
 	}
-	return 0, fmt.Errorf("could not find function: %s", funcname)
+	return b.stub.isStub(buff), nil
+}
+
+//moduleBase returns where the module is loaded in this process. Memory modes know this from load, disk mode looks it up in the PEB the first time it's needed. Callers must hold b.mu.
+func (b *BananaPhone) moduleBase() (uintptr, error) {
+	if b.memloc != 0 {
+		return b.memloc, nil
+	}
+	if b.external {
+		return 0, errors.New("module was provided without a base address")
+	}
+	base, _, e := GetModuleBase(b.diskpath)
+	if e != nil {
+		base, _, e = GetModuleBase(b.name)
+	}
+	if e != nil {
+		return 0, fmt.Errorf("%s has no in-memory address: %w", b.name, e)
+	}
+	b.memloc = base
+	return base, nil
 }
 
 //BananaProc emulates the windows proc thing
 type BananaProcedure struct {
 	address uintptr
+	//err is why the procedure couldn't be resolved, NewProc doesn't get to return it
+	err error
+}
+
+//Err returns the error from resolving the procedure, if it couldn't be. Addr is 0 and Call fails with this error if so.
+func (b BananaProcedure) Err() error {
+	return b.err
 }
 
 //Addr returns the address of this procedure
@@ -116,44 +407,151 @@ func (b BananaProcedure) Addr() uintptr {
 	return b.address
 }
 
+//Call calls the procedure with CallFunction. There's no GetLastError to hand back like windows.Proc.Call, just RAX.
+func (b BananaProcedure) Call(argh ...uintptr) (uintptr, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	return CallFunction(b.address, argh...)
+}
+
 //NewProc emulates the windows NewProc call :-)
 func (b *BananaPhone) NewProc(funcname string) BananaProcedure {
-	addr, _ := b.GetFuncPtr(funcname) //yolo error handling
-	return BananaProcedure{address: uintptr(addr)}
+	addr, e := b.GetFuncPtr(funcname) //yolo error handling, but Call will know
+	return BananaProcedure{address: uintptr(addr), err: e}
+}
+
+//Syscall is the package level Syscall (run on the phone's Executor, if it has one), so a BananaPhone satisfies SyscallResolver.
+func (b *BananaPhone) Syscall(sysid uint16, argh ...uintptr) (errcode uint32, err error) {
+	if b.exec != nil {
+		return b.exec.Syscall(sysid, argh...)
+	}
+	return Syscall(sysid, argh...)
 }
 
-//GetSysID resolves the provided function name into a sysid.
+//GetSysID resolves the provided function name into a sysid. Resolved values are cached, see InvalidateCache.
 func (b *BananaPhone) GetSysID(funcname string) (uint16, error) {
-	useneighbor := false
-	switch b.mode {
-	case HalosGateBananaPhoneMode:
-		fallthrough
-	case AutoBananaPhoneMode:
-		useneighbor = true
+	if id, ok := b.overrides[funcname]; ok {
+		return id, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, e := b.getSysIDDetailed(funcname)
+	return r.SysID, e
+}
+
+//Resolution is a sysid along with how it was worked out, see GetSysIDDetailed.
+type Resolution struct {
+	SysID uint16
+	//Source is where the sysid came from: "memory" (the stub was clean), "halos gate", "disk" (Auto mode fell back, or the phone is in disk mode), "persistent cache", "feed" (see WithSysIDFeed) or "override".
+	Source string
+	//Distance is how many stubs away the clean neighbour used by Halo's Gate was - positive if it was after the function, negative if before. It's 0 for every other source.
+	Distance int
+}
+
+//GetSysIDDetailed is GetSysID, but also says where the sysid came from. A cached sysid reports how it was originally resolved.
+func (b *BananaPhone) GetSysIDDetailed(funcname string) (Resolution, error) {
+	if id, ok := b.overrides[funcname]; ok {
+		return Resolution{SysID: id, Source: "override"}, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.getSysIDDetailed(funcname)
+}
+
+//getSysIDDetailed returns the cached resolution of funcname, resolving and caching it if there isn't one. Callers must hold b.mu.
+func (b *BananaPhone) getSysIDDetailed(funcname string) (Resolution, error) {
+	if id, ok := b.cachedSysID(funcname); ok {
+		return b.cachedResolution(funcname, id), nil
 	}
-	r, e := b.getSysID(funcname, 0, false, useneighbor)
+	r, e := b.resolveSysID(funcname)
 	if e != nil {
-		var err MayBeHookedError
-		// error is some other error besides an indicator that we are being hooked
-		if !errors.Is(e, &err) {
-			return r, e
-		}
+		return Resolution{}, e
+	}
+	b.storeResolution(funcname, r)
+	b.savePersistentCache()
+	return r, nil
+}
 
-		//fall back to disk only if in auto mode
-		if b.mode == AutoBananaPhoneMode {
-			var e2 error
-			b.banana, e2 = pe.Open(`C:\Windows\system32\ntdll.dll`)
-			if e2 != nil {
-				return 0, e2
-			}
-			r, e = b.getSysID(funcname, 0, false, false) //using disk mode her
+//GetSysIDBatch resolves a list of function names in one go. The lock is held for the whole batch, so nothing else can refresh or invalidate the phone while it's going and the exports are only walked once. Each name gets the same fallbacks as GetSysID, and a name that fails to resolve has its error in the second map rather than stopping the batch.
+func (b *BananaPhone) GetSysIDBatch(names []string) (map[string]uint16, map[string]error) {
+	ids := make(map[string]uint16, len(names))
+	errs := map[string]error{}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	resolved := false
+	for _, n := range names {
+		if id, ok := b.overrides[n]; ok {
+			ids[n] = id
+			continue
+		}
+		if id, ok := b.cachedSysID(n); ok {
+			ids[n] = id
+			continue
 		}
+		r, e := b.resolveSysID(n)
+		if e != nil {
+			errs[n] = e
+			continue
+		}
+		b.storeResolution(n, r)
+		ids[n] = r.SysID
+		resolved = true
+	}
+	if resolved {
+		b.savePersistentCache()
 	}
-	return r, e
+	return ids, errs
 }
 
-//GetSysIDOrd resolves the provided ordinal into a sysid.
+//resolveSysID does the resolution for GetSysID. Callers must hold b.mu.
+func (b *BananaPhone) resolveSysID(funcname string) (Resolution, error) {
+	return b.resolveChain(funcname, 0, false)
+}
+
+//GetSysIDOrd resolves the provided ordinal into a sysid. Resolved values are cached, see InvalidateCache.
 func (b *BananaPhone) GetSysIDOrd(ordinal uint32) (uint16, error) {
+	if id, ok := b.overrides[ordKey(ordinal)]; ok {
+		return id, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if id, ok := b.cachedSysID(ordKey(ordinal)); ok {
+		return id, nil
+	}
+	r, e := b.resolveSysIDOrd(ordinal)
+	if e != nil {
+		return 0, e
+	}
+	b.storeResolution(ordKey(ordinal), r)
+	b.savePersistentCache()
+	return r.SysID, nil
+}
+
+//resolveSysIDOrd does the resolution for GetSysIDOrd. Callers must hold b.mu.
+func (b *BananaPhone) resolveSysIDOrd(ordinal uint32) (Resolution, error) {
+	return b.resolveChain("", ordinal, true)
+}
+
+//resolveChain runs the fallbacks the mode allows for a name or ordinal (see getSysID for useOrd), so both kinds of lookup get exactly the same treatment: the stub itself, then Halo's Gate for Auto and HalosGate modes, then the disk copy for Auto mode, and finally the feed if the phone has one. Callers must hold b.mu.
+func (b *BananaPhone) resolveChain(funcname string, ord uint32, useOrd bool) (Resolution, error) {
+	label := funcname
+	if useOrd {
+		label = ordKey(ord)
+	}
+	res, e := b.resolveModule(label, funcname, ord, useOrd)
+	if e != nil {
+		if id, ok := b.feedSysID(label); ok {
+			res = Resolution{SysID: id, Source: "feed"}
+			trace(TraceEvent{Kind: TraceResolved, Mode: b.mode, Func: label, SysID: id, Detail: res.Source, Err: e})
+			return res, nil
+		}
+	}
+	return res, e
+}
+
+//resolveModule is resolveChain without the feed, working everything out from the module. Callers must hold b.mu.
+func (b *BananaPhone) resolveModule(label, funcname string, ord uint32, useOrd bool) (Resolution, error) {
 	useneighbor := false
 	switch b.mode {
 	case HalosGateBananaPhoneMode:
@@ -162,81 +560,145 @@ func (b *BananaPhone) GetSysIDOrd(ordinal uint32) (uint16, error) {
 		useneighbor = true
 	}
 
-	r, e := b.getSysID("", ordinal, true, useneighbor)
+	r, distance, e := b.getSysID(funcname, ord, useOrd, useneighbor)
 	if e != nil {
 		var err MayBeHookedError
-		//error that is not hooked error
-		if !errors.Is(e, &err) {
-			return r, e
+		// error is some other error besides an indicator that we are being hooked (or that the module has been mangled)
+		if !errors.As(e, &err) && !errors.Is(e, ErrMalformedImage) {
+			return Resolution{}, e
 		}
 
-		//error just indicated the bytes were not as expected. Continue here.
+		//fall back to disk only if in auto mode
 		if b.mode == AutoBananaPhoneMode {
-			var e2 error
-			b.banana, e2 = pe.Open(`C:\Windows\system32\ntdll.dll`)
-			if e2 != nil {
-				return 0, e2
+			if e2 := b.fallBackToDisk(label, e); e2 != nil {
+				return Resolution{}, e2
 			}
-			r, e = b.getSysID("", ordinal, true, false) //using disk mode here
+			r, distance, e = b.getSysID(funcname, ord, useOrd, false) //using disk mode here
 		}
 	}
-	return r, e
+	if e != nil {
+		return Resolution{}, e
+	}
+	res := Resolution{SysID: r, Source: b.source(), Distance: distance}
+	if distance != 0 {
+		res.Source = "halos gate"
+	}
+	trace(TraceEvent{Kind: TraceResolved, Mode: b.mode, Func: label, SysID: r, Detail: res.Source})
+	return res, nil
 }
 
-//getSysID does the heavy lifting - will resolve a name or ordinal into a sysid by getting exports, and parsing the first few bytes of the function to extract the ID. Doens't look at the ord value unless useOrd is set to true.
-func (b BananaPhone) getSysID(funcname string, ord uint32, useOrd, useneighbor bool) (uint16, error) {
-	ex, e := b.banana.Exports()
+//getSysID does the heavy lifting - will resolve a name or ordinal into a sysid by getting exports, and parsing the first few bytes of the function to extract the ID. Doens't look at the ord value unless useOrd is set to true. If Halo's Gate was used, the distance to the neighbour it used is returned as well (negative for backwards).
+func (b *BananaPhone) getSysID(funcname string, ord uint32, useOrd, useneighbor bool) (uint16, int, error) {
+	ex, e := b.getExports()
 	if e != nil {
-		return 0, e
+		return 0, 0, e
 	}
 
 	for _, exp := range ex {
-		if (useOrd && exp.Ordinal == ord) || // many bothans died for this feature (thanks awgh). Turns out that a value can be exported by ordinal, but not by name! man I love PE files. ha ha jk.
-			exp.Name == funcname {
-			offset := rvaToOffset(b.banana, exp.VirtualAddress)
-			bBytes, e := b.banana.Bytes()
+		if exportMatches(exp, funcname, ord, useOrd) { //thanks awgh. Turns out that a value can be exported by ordinal, but not by name! man I love PE files. ha ha jk.
+			buff, e := readRVA(b.banana, exp.VirtualAddress, 10)
 			if e != nil {
-				return 0, e
+				return 0, 0, e
 			}
-			buff := bBytes[offset : offset+10]
 
 			sysId, e := sysIDFromRawBytes(buff)
 			var err MayBeHookedError
+			label := exp.Name
+			if useOrd {
+				label = ordKey(exp.Ordinal)
+			}
+			if errors.As(e, &err) {
+				//not being a syscall at all isn't something any of the fallbacks can help with
+				if ok, e2 := b.isSyscall(exp.VirtualAddress); e2 == nil && !ok {
+					return 0, 0, fmt.Errorf("%w: %s", ErrNotASyscall, label)
+				}
+				trace(TraceEvent{Kind: TraceHookDetected, Mode: b.mode, Func: label, Detail: fmt.Sprintf("%x", err.Foundbytes)})
+			}
 			// Look for the syscall ID in the neighborhood
 			if errors.As(e, &err) && useneighbor {
+				hookErr := e
 				// big thanks to @nodauf for implementing the halos gate logic
-				start, size := GetNtdllStart()
+				text, textRVA, e := b.sectionData(exp.VirtualAddress)
+				if e != nil {
+					return 0, 0, e
+				}
+				offset := int(exp.VirtualAddress - textRVA)
+				nextStub := int(b.stub.nextStubDistance())
 				distanceNeighbor := 0
 				// Search forward
-				for i := uintptr(offset); i < start+size; i += 1 {
-					if bBytes[i] == byte('\x0f') && bBytes[i+1] == byte('\x05') && bBytes[i+2] == byte('\xc3') {
+				for i := offset; i+3 <= len(text); i++ {
+					if text[i] == byte('\x0f') && text[i+1] == byte('\x05') && text[i+2] == byte('\xc3') {
 						distanceNeighbor++
-						// The sysid should be located 14 bytes after the syscall; ret instruction.
-						sysId, e := sysIDFromRawBytes(bBytes[i+14 : i+14+8])
+						// The sysid should be located at the start of the next stub, which is 14 bytes after the syscall; ret instruction on modern builds.
+						next := i + nextStub
+						if next+8 > len(text) {
+							break
+						}
+						sysId, e := sysIDFromRawBytes(text[next : next+8])
 						if !errors.As(e, &err) {
-							return sysId - uint16(distanceNeighbor), e
+							sysId -= uint16(distanceNeighbor)
+							trace(TraceEvent{Kind: TraceHalosGate, Mode: b.mode, Func: label, SysID: sysId, Detail: fmt.Sprintf("%d stubs forward", distanceNeighbor)})
+							return sysId, distanceNeighbor, e
 						}
 					}
 				}
 				// reset the value to 1. When we go forward we catch the current syscall; ret but not when we go backward, so distanceNeighboor = 0 for forward and distanceNeighboor = 1 for backward
 				distanceNeighbor = 1
 				// If nothing has been found forward, search backward
-				for i := uintptr(offset) - 1; i > 0; i -= 1 {
-					if bBytes[i] == byte('\x0f') && bBytes[i+1] == byte('\x05') && bBytes[i+2] == byte('\xc3') {
+				for i := offset - 1; i > 0; i-- {
+					if text[i] == byte('\x0f') && text[i+1] == byte('\x05') && text[i+2] == byte('\xc3') {
 						distanceNeighbor++
-						// The sysid should be located 14 bytes after the syscall; ret instruction.
-						sysId, e := sysIDFromRawBytes(bBytes[i+14 : i+14+8])
+						// The sysid should be located at the start of the next stub, which is 14 bytes after the syscall; ret instruction on modern builds.
+						next := i + nextStub
+						if next+8 > len(text) {
+							continue
+						}
+						sysId, e := sysIDFromRawBytes(text[next : next+8])
 						if !errors.As(e, &err) {
-							return sysId + uint16(distanceNeighbor) - 1, e
+							sysId += uint16(distanceNeighbor) - 1
+							trace(TraceEvent{Kind: TraceHalosGate, Mode: b.mode, Func: label, SysID: sysId, Detail: fmt.Sprintf("%d stubs back", distanceNeighbor-1)})
+							return sysId, -(distanceNeighbor - 1), e
 						}
 					}
 				}
+				//no clean neighbour either way, let the caller decide whether there's anything else to try
+				return 0, 0, hookErr
 			} else {
-				return sysId, e
+				return sysId, 0, e
 			}
 		}
 	}
-	return 0, errors.New("could not find syscall ID")
+	if useOrd {
+		return 0, 0, fmt.Errorf("%w: ordinal %d", ErrFunctionNotFound, ord)
+	}
+	return 0, 0, fmt.Errorf("%w: %s", ErrFunctionNotFound, funcname)
+}
+
+//fallBackToDisk switches the phone over to the on-disk copy of its module (b.diskpath) for Auto mode. If the disk copy isn't the same build as the one that was loaded, the sysids it gives may not match what the kernel expects, so a TraceVersionMismatch event is sent. Callers must hold b.mu.
+func (b *BananaPhone) fallBackToDisk(label string, cause error) error {
+	trace(TraceEvent{Kind: TraceFallback, Mode: b.mode, Func: label, Err: cause})
+	p, e := openPE(b.diskpath)
+	if e != nil {
+		return fmt.Errorf("falling back to disk: %w", e)
+	}
+	if b.banana != nil {
+		if m := versionMismatch(b.banana, p); m != "" {
+			trace(TraceEvent{Kind: TraceVersionMismatch, Mode: b.mode, Func: label, Detail: m})
+		}
+		b.banana.Close() //a previous fallback's disk copy holds a handle open
+	}
+	b.banana = p
+	b.invalidateExports()
+	b.fellBack = true
+	return nil
+}
+
+//source describes where the phone is currently resolving from, for trace events.
+func (b *BananaPhone) source() string {
+	if b.mode == DiskBananaPhoneMode || b.fellBack {
+		return "disk"
+	}
+	return "memory"
 }
 
 //MayBeHookedError an error returned when trying to extract the sysid from a resolved function. Contains the bytes that were actually found (incase it's useful to someone?)
@@ -248,6 +710,11 @@ func (e MayBeHookedError) Error() string {
 	return fmt.Sprintf("may be hooked: wanted %x got %x", HookCheck, e.Foundbytes)
 }
 
+//Is makes a MayBeHookedError match ErrUnsupportedStub.
+func (e MayBeHookedError) Is(target error) bool {
+	return target == ErrUnsupportedStub
+}
+
 //HookCheck is the bytes expected to be seen at the start of the function:
 /*
 	mov r10, rcx ;(4c 8b d1)
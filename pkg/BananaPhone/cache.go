@@ -0,0 +1,126 @@
+package bananaphone
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+//persistentCache is the on-disk copy of a phone's resolved sysids, see WithPersistentCache.
+type persistentCache struct {
+	path string
+	aead cipher.AEAD
+	err  error //set if the key was no good, reported by the constructor
+}
+
+//persistentCacheFile is what gets encrypted into the cache file.
+type persistentCacheFile struct {
+	Module        string            `json:"module"`
+	TimeDateStamp uint32            `json:"timestamp"`
+	SysIDs        map[string]uint16 `json:"sysids"`
+}
+
+//WithPersistentCache keeps the phone's resolved sysids in a file at path, encrypted and authenticated with AES-GCM using key (16, 24 or 32 bytes). On creation, the cached sysids are loaded if the file was written for the same module with the same TimeDateStamp, so after a patch the numbers are thrown away rather than served stale. New sysids are written back as they're resolved. A missing, stale, corrupt or tampered file is just ignored. Failing to write the file doesn't fail the lookup, it's reported as a TraceCacheWriteFailed event and the last such error is returned by Close.
+func WithPersistentCache(path string, key []byte) Option {
+	return func(b *BananaPhone) {
+		c := &persistentCache{path: path}
+		block, e := aes.NewCipher(key)
+		if e == nil {
+			c.aead, e = cipher.NewGCM(block)
+		}
+		c.err = e
+		b.cache = c
+	}
+}
+
+//timeDateStamp returns the TimeDateStamp of the module the phone resolves against. Callers must hold b.mu.
+func (b *BananaPhone) timeDateStamp() (uint32, bool) {
+	if b.banana == nil {
+		return 0, false
+	}
+	return b.banana.FileHeader.TimeDateStamp, true
+}
+
+//readPersistentCache fills the sysid cache from the cache file, if it matches the module. Callers must hold b.mu.
+func (b *BananaPhone) readPersistentCache() error {
+	if b.cache == nil {
+		return nil
+	}
+	ts, ok := b.timeDateStamp()
+	if !ok {
+		return nil
+	}
+	data, e := ioutil.ReadFile(b.cache.path)
+	if e != nil {
+		return e
+	}
+	n := b.cache.aead.NonceSize()
+	if len(data) < n {
+		return errors.New("cache file is too short")
+	}
+	plain, e := b.cache.aead.Open(nil, data[:n], data[n:], []byte(b.name))
+	if e != nil {
+		return e
+	}
+	var f persistentCacheFile
+	if e = json.Unmarshal(plain, &f); e != nil {
+		return e
+	}
+	if f.Module != b.name || f.TimeDateStamp != ts {
+		return errors.New("cache file is for a different module")
+	}
+	for k, v := range f.SysIDs {
+		b.storeResolution(k, Resolution{SysID: v, Source: "persistent cache"})
+	}
+	return nil
+}
+
+//savePersistentCache writes the sysid cache file, reporting a failure as a TraceCacheWriteFailed event and keeping it for Close to return. Callers must hold b.mu.
+func (b *BananaPhone) savePersistentCache() {
+	if e := b.writePersistentCache(); e != nil {
+		b.cacheErr = e
+		trace(TraceEvent{Kind: TraceCacheWriteFailed, Mode: b.mode, Detail: b.cache.path, Err: e})
+	}
+}
+
+//writePersistentCache writes the sysid cache to the cache file. The file is replaced, never partially written. Callers must hold b.mu.
+func (b *BananaPhone) writePersistentCache() error {
+	if b.cache == nil || b.hard != nil {
+		return nil
+	}
+	ts, ok := b.timeDateStamp()
+	if !ok {
+		return nil
+	}
+	plain, e := json.Marshal(persistentCacheFile{Module: b.name, TimeDateStamp: ts, SysIDs: b.ssnCache})
+	if e != nil {
+		return e
+	}
+	nonce := make([]byte, b.cache.aead.NonceSize())
+	if _, e = io.ReadFull(rand.Reader, nonce); e != nil {
+		return e
+	}
+	data := b.cache.aead.Seal(nonce, nonce, plain, []byte(b.name))
+
+	tmp, e := ioutil.TempFile(filepath.Dir(b.cache.path), filepath.Base(b.cache.path)+".tmp")
+	if e != nil {
+		return e
+	}
+	_, e = tmp.Write(data)
+	if e2 := tmp.Close(); e == nil {
+		e = e2
+	}
+	if e == nil {
+		e = os.Rename(tmp.Name(), b.cache.path)
+	}
+	if e != nil {
+		os.Remove(tmp.Name())
+	}
+	return e
+}
@@ -0,0 +1,92 @@
+package bananaphone
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/C-Sto/BananaPhone/pkg/BananaPhone/ntconst"
+)
+
+//CarveModule pulls a module loaded in this process out of memory and turns it back into something that looks like the file it came from, for offline analysis. The mapped image is read with ReadProcessMemory (so a page that can't be read is an error, not a crash), each section is moved from its virtual address back to a FileAlignment aligned file offset, and the section headers are fixed up to match. ImageBase is set to where the module is actually loaded, since the loader has already applied relocations.
+func (b *BananaPhone) CarveModule(name string) ([]byte, error) {
+	base, size, e := GetModuleBase(name)
+	if e != nil {
+		return nil, e
+	}
+	img, e := b.ReadProcessMemory(ntconst.CurrentProcess, base, int(size))
+	if e != nil {
+		return nil, e
+	}
+	return unmapImage(img, uint64(base))
+}
+
+//unmapImage converts a mapped image (section data at virtual addresses) into file layout, see CarveModule.
+func unmapImage(img []byte, base uint64) ([]byte, error) {
+	le := binary.LittleEndian
+	if len(img) < 0x40 || img[0] != 'M' || img[1] != 'Z' {
+		return nil, errors.New("no DOS header")
+	}
+	nt := int(le.Uint32(img[0x3c:]))
+	if nt+24 > len(img) || le.Uint32(img[nt:]) != 0x4550 {
+		return nil, errors.New("no NT headers")
+	}
+	numSections := int(le.Uint16(img[nt+6:]))
+	optSize := int(le.Uint16(img[nt+20:]))
+	opt := nt + 24
+	sections := opt + optSize
+	if opt+64 > len(img) || sections+numSections*40 > len(img) {
+		return nil, errors.New("headers run past the end of the image")
+	}
+
+	fileAlign := int(le.Uint32(img[opt+36:]))
+	if fileAlign == 0 {
+		fileAlign = 0x200
+	}
+	headerSize := int(le.Uint32(img[opt+60:]))
+	if headerSize < sections+numSections*40 || headerSize > len(img) {
+		headerSize = sections + numSections*40
+	}
+	align := func(n int) int {
+		return (n + fileAlign - 1) / fileAlign * fileAlign
+	}
+
+	out := make([]byte, align(headerSize))
+	copy(out, img[:headerSize])
+	//the COFF symbol table (if there is one) isn't mapped, so don't point at it
+	le.PutUint32(out[nt+12:], 0)
+	le.PutUint32(out[nt+16:], 0)
+	switch le.Uint16(img[opt:]) {
+	case 0x20b: //PE32+
+		le.PutUint64(out[opt+24:], base)
+	case 0x10b: //PE32
+		le.PutUint32(out[opt+28:], uint32(base))
+	default:
+		return nil, fmt.Errorf("unknown optional header magic %#x", le.Uint16(img[opt:]))
+	}
+
+	for i := 0; i < numSections; i++ {
+		hdr := sections + i*40
+		vsize := int(le.Uint32(img[hdr+8:]))
+		va := int(le.Uint32(img[hdr+12:]))
+		if vsize == 0 {
+			vsize = int(le.Uint32(img[hdr+16:]))
+		}
+		if va > len(img) {
+			vsize = 0
+		} else if va+vsize > len(img) {
+			vsize = len(img) - va
+		}
+		if vsize == 0 {
+			le.PutUint32(out[hdr+16:], 0)
+			le.PutUint32(out[hdr+20:], 0)
+			continue
+		}
+		off := len(out)
+		out = append(out, img[va:va+vsize]...)
+		out = append(out, make([]byte, align(len(out))-len(out))...)
+		le.PutUint32(out[hdr+16:], uint32(len(out)-off))
+		le.PutUint32(out[hdr+20:], uint32(off))
+	}
+	return out, nil
+}
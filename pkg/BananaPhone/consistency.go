@@ -0,0 +1,86 @@
+package bananaphone
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//SysIDAnomaly is a syscall whose sysid doesn't fit with the others, see CheckConsistency.
+type SysIDAnomaly struct {
+	//Func is the Zw* export.
+	Func string
+	//Expected is the sysid the stub's position implies - syscalls are numbered in the same order as their stubs are laid out.
+	Expected uint16
+	//Resolved is what the phone resolved, unless Err is set.
+	Resolved uint16
+	Err      error
+	//Stub is the sysid in the stub itself, if StubClean is set. A clean stub that disagrees with Expected means stubs have been moved around.
+	Stub      uint16
+	StubClean bool
+}
+
+func (a SysIDAnomaly) String() string {
+	stub := "hooked"
+	if a.StubClean {
+		stub = fmt.Sprintf("%#x", a.Stub)
+	}
+	if a.Err != nil {
+		return fmt.Sprintf("%s: expected %#x, stub %s, error: %v", a.Func, a.Expected, stub, a.Err)
+	}
+	return fmt.Sprintf("%s: expected %#x, resolved %#x, stub %s", a.Func, a.Expected, a.Resolved, stub)
+}
+
+//CheckConsistency cross-checks the phone's sysids against the layout of the module: the Zw* exports sorted by address should number up from the first one without gaps, and each resolved sysid (and each clean stub) should agree with its position. Anything that doesn't is returned. Halo's Gate and friends assume stubs haven't been moved, so a hooking scheme that relocates whole stubs can make them give wrong numbers without any error - this is how you find out. Sysids are resolved (and cached) with the phone's normal fallbacks.
+func (b *BananaPhone) CheckConsistency() ([]SysIDAnomaly, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	exports, e := b.getExports()
+	if e != nil {
+		return nil, e
+	}
+	type stub struct {
+		name  string
+		rva   uint32
+		id    uint16
+		clean bool
+	}
+	stubs := []stub{}
+	for _, ex := range exports {
+		if strings.HasPrefix(ex.Name, "Zw") {
+			stubs = append(stubs, stub{name: ex.Name, rva: ex.VirtualAddress})
+		}
+	}
+	sort.Slice(stubs, func(i, j int) bool { return stubs[i].rva < stubs[j].rva })
+
+	//read the stubs before resolving anything, in case Auto mode falls back to disk part way through
+	base := -1
+	for i := range stubs {
+		s := &stubs[i]
+		buff, e := readRVA(b.banana, s.rva, len(HookCheck)+4)
+		if e != nil {
+			continue
+		}
+		if id, e := sysIDFromRawBytes(buff); e == nil {
+			s.id, s.clean = id, true
+			//the numbering doesn't always start at 0 (win32u starts at 0x1000), take it from the first clean stub
+			if base < 0 {
+				base = int(id) - i
+			}
+		}
+	}
+	if base < 0 {
+		base = 0
+	}
+
+	ret := []SysIDAnomaly{}
+	for i, s := range stubs {
+		a := SysIDAnomaly{Func: s.name, Expected: uint16(base + i), Stub: s.id, StubClean: s.clean}
+		r, e := b.getSysIDDetailed(s.name)
+		a.Resolved, a.Err = r.SysID, e
+		if e != nil || r.SysID != a.Expected || (s.clean && s.id != a.Expected) {
+			ret = append(ret, a)
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,47 @@
+package bananaphone
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	defaultOnce  sync.Once
+	defaultPhone *BananaPhone
+	defaultErr   error
+)
+
+//Default returns a package level AutoBananaPhoneMode phone for ntdll, created on first use. It's safe to call from multiple goroutines. If creating the phone failed the result is nil - SysID and Call will tell you why.
+func Default() *BananaPhone {
+	defaultOnce.Do(func() {
+		defaultPhone, defaultErr = NewBananaPhone(AutoBananaPhoneMode)
+	})
+	return defaultPhone
+}
+
+//defaultOrErr returns the default phone, or the error that stopped it being created.
+func defaultOrErr() (*BananaPhone, error) {
+	bp := Default()
+	if bp == nil {
+		return nil, fmt.Errorf("BananaPhone uninitialised: %w", defaultErr)
+	}
+	return bp, nil
+}
+
+//SysID resolves the named function into a sysid using the Default phone.
+func SysID(funcname string) (uint16, error) {
+	bp, e := defaultOrErr()
+	if e != nil {
+		return 0, e
+	}
+	return bp.GetSysID(funcname)
+}
+
+//Call resolves the named function using the Default phone and calls it with Syscall.
+func Call(funcname string, argh ...uintptr) (errcode uint32, err error) {
+	sysid, e := SysID(funcname)
+	if e != nil {
+		return 0, e
+	}
+	return Syscall(sysid, argh...)
+}
@@ -0,0 +1,135 @@
+package bananaphone
+
+import "sync"
+
+//BananaDLL is a module that's already loaded in this process, found by walking the PEB instead of calling LoadLibrary. It has the same shape as windows.DLL, so code written against windows.MustLoadDLL/FindProc can move over without much fuss. Nothing is ever loaded - if the process doesn't have the module, NewBananaDLL fails.
+type BananaDLL struct {
+	Name string
+	//Handle is the module base, which is what an HMODULE is.
+	Handle uintptr
+
+	mu    sync.Mutex
+	procs map[string]BananaProcedure
+}
+
+//NewBananaDLL finds the named module (see GetModuleBase for the forms the name can take) in the PEB.
+func NewBananaDLL(name string) (*BananaDLL, error) {
+	base, _, e := GetModuleBase(name)
+	if e != nil {
+		return nil, e
+	}
+	return &BananaDLL{Name: name, Handle: base, procs: map[string]BananaProcedure{}}, nil
+}
+
+//FindProc resolves an export of the module with ResolveExport, so forwarders and "#123" ordinals work. Procedures are cached, each name is only resolved once.
+func (d *BananaDLL) FindProc(name string) (BananaProcedure, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if p, ok := d.procs[name]; ok {
+		return p, nil
+	}
+	addr, e := ResolveExport(d.Name, name)
+	if e != nil {
+		return BananaProcedure{}, e
+	}
+	p := BananaProcedure{address: addr}
+	if d.procs == nil {
+		d.procs = map[string]BananaProcedure{}
+	}
+	d.procs[name] = p
+	return p, nil
+}
+
+//MustFindProc is FindProc, but panics if the export can't be found.
+func (d *BananaDLL) MustFindProc(name string) BananaProcedure {
+	p, e := d.FindProc(name)
+	if e != nil {
+		panic(e)
+	}
+	return p
+}
+
+//BananaLazyDLL is the lazy version of BananaDLL, like windows.LazyDLL. The module isn't looked up until something needs it, so these can be package level vars.
+type BananaLazyDLL struct {
+	Name string
+
+	mu  sync.Mutex
+	dll *BananaDLL
+}
+
+//NewBananaLazyDLL creates a BananaLazyDLL for the named module. Nothing is looked up yet.
+func NewBananaLazyDLL(name string) *BananaLazyDLL {
+	return &BananaLazyDLL{Name: name}
+}
+
+//Load finds the module in the PEB, if it hasn't been found already. A failed lookup isn't remembered, so it can be tried again after the module has been loaded.
+func (d *BananaLazyDLL) Load() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dll != nil {
+		return nil
+	}
+	dll, e := NewBananaDLL(d.Name)
+	if e != nil {
+		return e
+	}
+	d.dll = dll
+	return nil
+}
+
+//Handle returns the module base. It panics if the module can't be found.
+func (d *BananaLazyDLL) Handle() uintptr {
+	if e := d.Load(); e != nil {
+		panic(e)
+	}
+	return d.dll.Handle
+}
+
+//NewProc returns a BananaLazyProc for an export of the module. Nothing is resolved yet.
+func (d *BananaLazyDLL) NewProc(name string) *BananaLazyProc {
+	return &BananaLazyProc{Name: name, l: d}
+}
+
+//BananaLazyProc is an export of a BananaLazyDLL that's resolved on first use, like windows.LazyProc.
+type BananaLazyProc struct {
+	Name string
+
+	mu    sync.Mutex
+	l     *BananaLazyDLL
+	found bool
+	proc  BananaProcedure
+}
+
+//Find loads the module and resolves the export, if that hasn't been done already. Once it has worked it's never done again. A failure isn't remembered, so a later call can try again.
+func (p *BananaLazyProc) Find() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.found {
+		return nil
+	}
+	if e := p.l.Load(); e != nil {
+		return e
+	}
+	proc, e := p.l.dll.FindProc(p.Name)
+	if e != nil {
+		return e
+	}
+	p.proc, p.found = proc, true
+	return nil
+}
+
+//Addr returns the address of the export. It panics if the export can't be found, same as windows.LazyProc.
+func (p *BananaLazyProc) Addr() uintptr {
+	if e := p.Find(); e != nil {
+		panic(e)
+	}
+	return p.proc.Addr()
+}
+
+//Call resolves the export if needed and calls it with CallFunction. Unlike windows.LazyProc.Call, failing to find the export is returned as an error rather than a panic.
+func (p *BananaLazyProc) Call(argh ...uintptr) (uintptr, error) {
+	if e := p.Find(); e != nil {
+		return 0, e
+	}
+	return p.proc.Call(argh...)
+}
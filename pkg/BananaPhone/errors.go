@@ -0,0 +1,45 @@
+package bananaphone
+
+import (
+	"errors"
+	"fmt"
+)
+
+//ErrUnsupportedPlatform is returned by everything that needs to poke at Windows process memory or make a syscall when the package is built for something other than windows/amd64. The package still compiles there so code that imports it can be cross compiled, check for this to gate things at runtime.
+var ErrUnsupportedPlatform = errors.New("bananaphone: only supported on windows/amd64")
+
+//These are the conditions worth branching on when resolution fails. Errors returned by the package wrap them (check with errors.Is) along with the details of what was being looked for, so there's no need to match on error text.
+var (
+	//ErrModuleNotFound means the module isn't in the PEB loader list of this process.
+	ErrModuleNotFound = errors.New("module not found")
+	//ErrFunctionNotFound means the module doesn't export the requested name or ordinal.
+	ErrFunctionNotFound = errors.New("function not found")
+	//ErrNotASyscall means the export exists, but it's a regular function rather than a syscall stub.
+	ErrNotASyscall = errors.New("not a syscall")
+	//ErrUnsupportedStub means the stub didn't look like a syscall stub, and the phone's mode had no fallback that could work the sysid out from somewhere else. The error is a MayBeHookedError, use errors.As to get the bytes that were found.
+	ErrUnsupportedStub = errors.New("unsupported syscall stub")
+	//ErrMalformedImage means a module's headers or export table point outside of it, eg because it's been tampered with. Auto mode treats this like a hooked stub and falls back to disk.
+	ErrMalformedImage = errors.New("malformed image")
+	//ErrGateNotFound means FindSyscallRet (and so SyscallRecycledGate) couldn't find the syscall; ret it was looking for in ntdll.
+	ErrGateNotFound = errors.New("no syscall gadget found")
+	//ErrGateTampered means the syscall; ret SyscallRecycledGate was using has been overwritten since it was found, see SetGateCheckPolicy.
+	ErrGateTampered = errors.New("syscall gadget has changed")
+	//ErrInvalidSysID means Syscall refused a sysid, see SetSysIDValidation.
+	ErrInvalidSysID = errors.New("invalid sysid")
+	//ErrAPISetNotFound means a forwarded export points at an api set (api-ms-win-*, ext-ms-*) that the PEB's ApiSetMap doesn't map to a host module, or the map uses a schema older than Windows 10's.
+	ErrAPISetNotFound = errors.New("api set not resolved")
+	//ErrModuleIndex means an index passed to GetModuleLoadedOrder is past the end of the PEB loader list, see GetModuleCount.
+	ErrModuleIndex = errors.New("module index out of range")
+)
+
+//NTStatus is an NTSTATUS returned by a syscall, as an error. Compare it against the ntconst STATUS_* values with errors.As.
+type NTStatus uint32
+
+func (s NTStatus) Error() string {
+	return fmt.Sprintf("NTSTATUS %#08x", uint32(s))
+}
+
+//IsSuccess is NT_SUCCESS - true for success and informational values (eg STATUS_PENDING), which have the top bit clear.
+func (s NTStatus) IsSuccess() bool {
+	return int32(s) >= 0
+}
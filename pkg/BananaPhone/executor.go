@@ -0,0 +1,71 @@
+package bananaphone
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+//ErrExecutorClosed is returned by an Executor that has been closed.
+var ErrExecutorClosed = errors.New("executor is closed")
+
+//Executor runs syscalls on one goroutine that's locked to its OS thread, so everything done through it happens on the same native thread. Go moves goroutines between threads whenever it likes, which matters when a sequence of calls depends on per-thread state: the last error/status in the TEB, an impersonation token set with NtSetInformationThread, or a thread's context. Use it directly, or give it to a phone with WithExecutor.
+type Executor struct {
+	reqs      chan func()
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+//NewExecutor starts an Executor's thread. Close it when you're done, or the thread hangs around for the life of the process.
+func NewExecutor() *Executor {
+	x := &Executor{reqs: make(chan func()), done: make(chan struct{})}
+	started := make(chan struct{})
+	go func() {
+		//never unlocked, so the thread is thrown away when the goroutine exits instead of going back to the scheduler with whatever state we left on it
+		runtime.LockOSThread()
+		close(started)
+		for {
+			select {
+			case fn := <-x.reqs:
+				fn()
+			case <-x.done:
+				return
+			}
+		}
+	}()
+	<-started
+	return x
+}
+
+//Do runs fn on the executor's thread and waits for it to finish. Calls are run one at a time, in the order they arrive.
+func (x *Executor) Do(fn func()) error {
+	finished := make(chan struct{})
+	select {
+	case x.reqs <- func() { defer close(finished); fn() }:
+	case <-x.done:
+		return ErrExecutorClosed
+	}
+	<-finished
+	return nil
+}
+
+//Syscall is the package level Syscall, run on the executor's thread.
+func (x *Executor) Syscall(callid uint16, argh ...uintptr) (errcode uint32, err error) {
+	if e := x.Do(func() { errcode, err = Syscall(callid, argh...) }); e != nil {
+		return 0, e
+	}
+	return errcode, err
+}
+
+//CallFunction is the package level CallFunction, run on the executor's thread.
+func (x *Executor) CallFunction(addr uintptr, argh ...uintptr) (ret uintptr, err error) {
+	if e := x.Do(func() { ret, err = CallFunction(addr, argh...) }); e != nil {
+		return 0, e
+	}
+	return ret, err
+}
+
+//Close stops the executor's thread once any call in progress has finished. Calls made after Close return ErrExecutorClosed.
+func (x *Executor) Close() {
+	x.closeOnce.Do(func() { close(x.done) })
+}
@@ -0,0 +1,60 @@
+package bananaphone
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+//SysIDFeed is a set of sysid tables keyed by the build number in the module's file version (not the OS build - ntdll on Windows 10 22H2, build 19045, says 10.0.19041.x), eg pushed out to a fleet so agents have numbers to fall back on for new builds without being rebuilt. syscalltable's Table.Feed makes them from ntdll files. Fetching it is up to you, see LoadSysIDFeed for the format, and WithSysIDFeed to use it.
+type SysIDFeed map[uint32]map[string]uint16
+
+//LoadSysIDFeed reads a feed from JSON: an object keyed by module build number, each holding an object of function name to sysid, eg
+//
+//	{"19041": {"NtClose": 15, "NtOpenProcess": 38}, "22621": {"NtClose": 15}}
+func LoadSysIDFeed(r io.Reader) (SysIDFeed, error) {
+	raw := map[string]map[string]uint16{}
+	if e := json.NewDecoder(r).Decode(&raw); e != nil {
+		return nil, fmt.Errorf("decoding sysid feed: %w", e)
+	}
+	f := make(SysIDFeed, len(raw))
+	for k, v := range raw {
+		build, e := strconv.ParseUint(k, 10, 32)
+		if e != nil {
+			return nil, fmt.Errorf("sysid feed: bad build number %q", k)
+		}
+		f[uint32(build)] = v
+	}
+	return f, nil
+}
+
+//Merge copies other into f, other's sysids winning where both have the same build and function.
+func (f SysIDFeed) Merge(other SysIDFeed) {
+	for build, ids := range other {
+		if f[build] == nil {
+			f[build] = make(map[string]uint16, len(ids))
+		}
+		for n, id := range ids {
+			f[build][n] = id
+		}
+	}
+}
+
+//ForBuild returns the table for a module build number, or nil if the feed doesn't have one.
+func (f SysIDFeed) ForBuild(build uint32) map[string]uint16 {
+	return f[build]
+}
+
+//feedSysID looks label up in the phone's feed, using the build from the file version of the module the phone resolves against. Callers must hold b.mu.
+func (b *BananaPhone) feedSysID(label string) (uint16, bool) {
+	if b.feed == nil || b.banana == nil {
+		return 0, false
+	}
+	v, e := moduleVersion(b.banana)
+	if e != nil {
+		return 0, false
+	}
+	id, ok := b.feed.ForBuild(uint32(v.Build))[label]
+	return id, ok
+}
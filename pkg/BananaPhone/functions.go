@@ -1,33 +1,92 @@
+//go:build windows && amd64
+// +build windows,amd64
+
 package bananaphone
 
 import (
+	"errors"
 	"fmt"
+	"sync"
 	"unsafe"
+
+	"github.com/C-Sto/BananaPhone/pkg/BananaPhone/kuser"
+	"github.com/C-Sto/BananaPhone/pkg/BananaPhone/ntconst"
 )
 
 //Syscall calls the system function specified by callid with n arguments. Works much the same as syscall.Syscall - return value is the call error code and optional error text. All args are uintptrs to make it easy.
 func Syscall(callid uint16, argh ...uintptr) (errcode uint32, err error) {
+	if len(argh) > maxCallArgs {
+		return ntconst.STATUS_INVALID_PARAMETER, fmt.Errorf("too many arguments: %d (max %d)", len(argh), maxCallArgs)
+	}
+	if e := checkSysID(callid); e != nil {
+		return ntconst.STATUS_INVALID_SYSTEM_SERVICE, e
+	}
 	errcode = bpSyscall(callid, argh...)
 	if errcode != 0 {
 		err = fmt.Errorf("non-zero return from syscall")
 	}
+	traceSyscall(callid, argh, errcode, err)
 	return errcode, err
 }
 
-//SyscallRecycledGate calls the system function specified by callid with n arguments. Works like Syscall but instead of executing the syscall instruction it will search for syscall;ret and jump on it
+//SyscallRecycledGate calls the system function specified by callid with n arguments. Works like Syscall but instead of executing the syscall instruction it will search for syscall;ret and jump on it. The gadget is found on the first call and reused, see SetGateCheckPolicy.
 func SyscallRecycledGate(callid uint16, argh ...uintptr) (errcode uint32, err error) {
 
-	//find the location of syscall;ret inside ntdll
-	jumpRetSyscall := findSyscallRet()
+	if len(argh) > maxCallArgs {
+		return ntconst.STATUS_INVALID_PARAMETER, fmt.Errorf("too many arguments: %d (max %d)", len(argh), maxCallArgs)
+	}
+	if e := checkSysID(callid); e != nil {
+		return ntconst.STATUS_INVALID_SYSTEM_SERVICE, e
+	}
+	//find the location of syscall;ret inside ntdll (or check the one found last time is still there)
+	jumpRetSyscall, e := recycledGate()
+	if e != nil {
+		return ntconst.STATUS_NOT_SUPPORTED, e
+	}
 	errcode = bpRecycledGateSyscall(callid, jumpRetSyscall, argh...)
 
 	if errcode != 0 {
 		err = fmt.Errorf("non-zero return from syscall")
 	}
+	traceSyscall(callid, argh, errcode, err)
 	return errcode, err
 }
 
-//Syscall calls the system function specified by callid with n arguments. Works much the same as syscall.Syscall - return value is the call error code and optional error text. All args are uintptrs to make it easy.
+//CallFunction calls the native function at addr (eg, something resolved with GetFuncPtr) with up to 16 arguments using the win64 calling convention, without going through syscall.SyscallN or the windows package. The function runs on a separate native stack (see callStackSize) with the TEB stack limits pointed at it for the duration, so __chkstk and SEH unwinding in the callee see a real thread stack rather than a goroutine's. Like Syscall, the goroutine isn't in syscall state while it runs, so a stop-the-world GC waits for the call to return - keep calls short, and don't call anything that calls back into Go (eg a syscall.NewCallback). Use an Executor's CallFunction to keep a series of calls on one OS thread. Only the RAX return value is provided; floats are passed through the XMM registers as raw bits for the first 4 args.
+func CallFunction(addr uintptr, argh ...uintptr) (uintptr, error) {
+	if addr == 0 {
+		return 0, errors.New("cannot call a nil function pointer")
+	}
+	if len(argh) > maxCallArgs {
+		return 0, fmt.Errorf("too many arguments: %d (max %d)", len(argh), maxCallArgs)
+	}
+	stack := callStacks.Get().(*[]byte)
+	defer callStacks.Put(stack)
+	var pin Pinner
+	defer pin.Unpin()
+	pin.Pin(&(*stack)[0])
+	bottom := uintptr(unsafe.Pointer(&(*stack)[0]))
+	//StackLimit is page aligned on a real thread stack
+	limit := (bottom + 0xfff) &^ 0xfff
+	return bpCallFunction(addr, bottom+callStackSize, limit, argh...), nil
+}
+
+//callStackSize is the size of the native stacks CallFunction runs functions on, the same as a Windows thread gets by default.
+const callStackSize = 1 << 20
+
+//callStacks are the native stacks for CallFunction, reused between calls. They're pinned while in use, and hold no Go pointers for the GC to care about.
+var callStacks = sync.Pool{New: func() interface{} {
+	s := make([]byte, callStackSize)
+	return &s
+}}
+
+//bpCallFunction calls the function at addr with the win64 ABI, on the native stack between stackLimit and stackTop. Args are not length checked.
+func bpCallFunction(addr, stackTop, stackLimit uintptr, argh ...uintptr) (ret uintptr)
+
+//maxCallArgs is the number of arguments the asm stubs have room for (maxargs in asm_x64.s). The syscall stubs crash if they're given more, so check before calling them.
+const maxCallArgs = 16
+
+//bpSyscall makes the syscall. Args are not length checked.
 func bpSyscall(callid uint16, argh ...uintptr) (errcode uint32)
 
 //bpRecycledGateSyscall calls the system function specified by callid with n arguments. Works like Syscall but instead of executing the syscall instruction it will search for syscall;ret and jump on it
@@ -36,47 +95,63 @@ func bpRecycledGateSyscall(callid uint16, jump uintptr, argh ...uintptr) (errcod
 //GetPEB returns the in-memory address of the start of PEB while making no api calls
 func GetPEB() uintptr
 
+//GetTEB returns the in-memory address of the start of the TEB of the current OS thread while making no api calls. Goroutines move between OS threads, so use runtime.LockOSThread if you care which thread's TEB you get.
+func GetTEB() uintptr
+
 //GetNtdllStart returns the start address of ntdll in memory
 func GetNtdllStart() (start uintptr, size uintptr)
 
-//getModuleLoadedOrder returns the start address of module located at i in the load order. This might be useful if there is a function you need that isn't in ntdll, or if some rude individual has loaded themselves before ntdll.
+//getModuleLoadedOrder returns the start address of module located at i in the load order. The index isn't checked - walking past the last module lands on the list head in PEB_LDR_DATA, which isn't a module.
 func getModuleLoadedOrder(i int) (start uintptr, size uintptr, modulepath *stupidstring)
 
-//GetModuleLoadedOrderPtr returns a pointer to the ldr data table entry in full, incase there is something interesting in there you want to see.
-func GetModuleLoadedOrderPtr(i int) *LdrDataTableEntry
+//getModuleLoadedOrderPtr returns a pointer to the ldr data table entry at i in the load order. The index isn't checked.
+func getModuleLoadedOrderPtr(i int) *LdrDataTableEntry
+
+//ldrInMemoryOrderOffset is the offset of InMemoryOrderModuleList in PEB_LDR_DATA.
+const ldrInMemoryOrderOffset = 0x20
+
+//GetModuleCount returns the number of modules in the PEB's in memory order module list, so the valid indexes for GetModuleLoadedOrder are 0 to GetModuleCount()-1. No API calls are made. Modules can come and go at any time, so it's only a snapshot.
+func GetModuleCount() int {
+	head := (*ListEntry)(uintptrToPointer(ReadPEB().Ldr + ldrInMemoryOrderOffset))
+	n := 0
+	for e := head.Flink; e != nil && e != head; e = e.Flink {
+		n++
+	}
+	return n
+}
+
+//GetModuleLoadedOrderPtr returns a pointer to the ldr data table entry in full, incase there is something interesting in there you want to see. Nil is returned if i is out of range.
+func GetModuleLoadedOrderPtr(i int) *LdrDataTableEntry {
+	if i < 0 || i >= GetModuleCount() {
+		return nil
+	}
+	return getModuleLoadedOrderPtr(i)
+}
 
-//GetModuleLoadedOrder returns the start address of module located at i in the load order. This might be useful if there is a function you need that isn't in ntdll, or if some rude individual has loaded themselves before ntdll.
-func GetModuleLoadedOrder(i int) (start uintptr, size uintptr, modulepath string) {
+//GetModuleLoadedOrder returns the start address of module located at i in the load order. This might be useful if there is a function you need that isn't in ntdll, or if some rude individual has loaded themselves before ntdll. ErrModuleIndex is returned if i is out of range.
+func GetModuleLoadedOrder(i int) (start uintptr, size uintptr, modulepath string, err error) {
+	if i < 0 || i >= GetModuleCount() {
+		return 0, 0, "", fmt.Errorf("%w: %d", ErrModuleIndex, i)
+	}
 	var badstring *stupidstring
 	start, size, badstring = getModuleLoadedOrder(i)
 	modulepath = badstring.String()
 	return
 }
 
-//Image contains info about a loaded image. Literally just a Base Addr and a Size - it should allow someone with a handy PE parser to pull the image out of memory...
-type Image struct {
-	BaseAddr uint64
-	Size     uint64
-}
-
 //InMemLoads returns a map of loaded dll paths to current process offsets (aka images) in the current process. No syscalls are made.
 func InMemLoads() (map[string]Image, error) {
 	ret := make(map[string]Image)
-	s, si, p := GetModuleLoadedOrder(0)
-	start := p
-	i := 1
-	ret[p] = Image{uint64(s), uint64(si)}
-	for {
-		s, si, p = GetModuleLoadedOrder(i)
+	for i, n := 0, GetModuleCount(); i < n; i++ {
+		s, si, p, e := GetModuleLoadedOrder(i)
+		if e != nil {
+			//the list shrank while we were walking it
+			break
+		}
 		if p != "" {
 			ret[p] = Image{uint64(s), uint64(si)}
 		}
-		if p == start {
-			break
-		}
-		i++
 	}
-
 	return ret, nil
 }
 
@@ -95,11 +170,36 @@ func GetSysIDFromDisk(funcname string) (uint16, error) {
 	return getSysIDFromDisk(funcname, 0, false)
 }
 
-//WriteMemory writes the provided memory to the specified memory address. Does **not** check permissions, may cause panic if memory is not writable etc.
-func WriteMemory(inbuf []byte, destination uintptr) {
-	for index := uint32(0); index < uint32(len(inbuf)); index++ {
-		writePtr := unsafe.Pointer(destination + uintptr(index))
-		v := (*byte)(writePtr)
-		*v = inbuf[index]
+//ReadPEB returns a copy of the current process' PEB. No API calls are made. Because it's a copy, writing to it won't change anything - that's the point.
+func ReadPEB() PEB {
+	return *(*PEB)(uintptrToPointer(GetPEB()))
+}
+
+//GetWindowsBuild returns the major, minor and build number of the running Windows without any API calls. The PEB is used if it has the values, otherwise they are read from KUSER_SHARED_DATA.
+func GetWindowsBuild() (major, minor, build uint32) {
+	p := ReadPEB()
+	if p.OSMajorVersion != 0 {
+		return p.OSMajorVersion, p.OSMinorVersion, uint32(p.OSBuildNumber)
 	}
+	return kuser.NtMajorVersion(), kuser.NtMinorVersion(), kuser.NtBuildNumber()
+}
+
+//ReadTEB returns a copy of the current OS thread's TEB. No API calls are made. See GetTEB about goroutines and threads.
+func ReadTEB() TEB {
+	return *(*TEB)(uintptrToPointer(GetTEB()))
+}
+
+//TEBTlsSlots returns a copy of the 64 static TLS slots of the current OS thread's TEB.
+func TEBTlsSlots() [tebTlsSlotsCount]uintptr {
+	return *(*[tebTlsSlotsCount]uintptr)(uintptrToPointer(GetTEB() + tebTlsSlotsOffset))
+}
+
+//GetLastError returns the current OS thread's TEB LastErrorValue, what kernel32's GetLastError would return, without calling it. Direct syscalls don't touch it, only ntdll/kernel32 functions that convert a status (eg anything called through CallFunction) do, so read it on the same thread straight after the call - see Executor.
+func GetLastError() uint32 {
+	return *(*uint32)(uintptrToPointer(GetTEB() + tebLastErrorOffset))
+}
+
+//GetLastNtStatus returns the current OS thread's TEB LastStatusValue, the NTSTATUS last handed to RtlNtStatusToDosError. It's sometimes more specific than the Win32 error it was converted to. The same thread caveats as GetLastError apply.
+func GetLastNtStatus() NTStatus {
+	return NTStatus(*(*uint32)(uintptrToPointer(GetTEB() + tebLastStatusOffset)))
 }
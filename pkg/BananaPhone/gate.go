@@ -0,0 +1,107 @@
+package bananaphone
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+//GateCheckPolicy decides how often SyscallRecycledGate makes sure the syscall; ret it found is still there before jumping to it, see SetGateCheckPolicy.
+type GateCheckPolicy int32
+
+const (
+	//GateCheckAlways re-reads the gadget before every call. It's 3 bytes, so this is the default.
+	GateCheckAlways GateCheckPolicy = iota
+	//GateCheckPeriodic re-reads the gadget every n calls.
+	GateCheckPeriodic
+	//GateCheckNever trusts the gadget once it's been found.
+	GateCheckNever
+)
+
+//defaultGateCheckEvery is how often GateCheckPeriodic checks if it isn't told.
+const defaultGateCheckEvery = 64
+
+var (
+	gateMu    sync.Mutex
+	gateAddr  uintptr //the syscall; ret SyscallRecycledGate uses, 0 until it's been found
+	gateCalls uint32
+
+	gatePolicy int32  //atomic GateCheckPolicy
+	gateEvery  uint32 //atomic, for GateCheckPeriodic
+)
+
+//SetGateCheckPolicy sets how often SyscallRecycledGate re-verifies its syscall; ret gadget. The gadget is found once and reused, so if ntdll has been re-patched (or re-mapped) since, jumping to it would run whatever is there now. A gadget that fails the check isn't jumped to - the call returns STATUS_NOT_SUPPORTED with an error wrapping ErrGateTampered, and the next call searches for a new one. every is only used by GateCheckPeriodic (0 means 64).
+func SetGateCheckPolicy(policy GateCheckPolicy, every uint32) {
+	if every == 0 {
+		every = defaultGateCheckEvery
+	}
+	atomic.StoreUint32(&gateEvery, every)
+	atomic.StoreInt32(&gatePolicy, int32(policy))
+}
+
+//recycledGate returns the syscall; ret gadget to use for the next SyscallRecycledGate, finding it if it hasn't been yet and checking it according to the policy otherwise.
+func recycledGate() (uintptr, error) {
+	gateMu.Lock()
+	defer gateMu.Unlock()
+	if gateAddr == 0 {
+		addr, e := FindSyscallRet(0)
+		if e != nil {
+			return 0, e
+		}
+		gateAddr = addr
+		gateCalls = 0
+		return gateAddr, nil
+	}
+	gateCalls++
+	check := false
+	switch GateCheckPolicy(atomic.LoadInt32(&gatePolicy)) {
+	case GateCheckAlways:
+		check = true
+	case GateCheckPeriodic:
+		every := atomic.LoadUint32(&gateEvery)
+		check = every == 0 || gateCalls%every == 0
+	}
+	if check {
+		buf := make([]byte, len(syscallRet))
+		unsafeReadMemory(gateAddr, buf)
+		if !bytes.Equal(buf, syscallRet) {
+			addr := gateAddr
+			gateAddr = 0
+			return 0, fmt.Errorf("%w: found % x at %#x", ErrGateTampered, buf, addr)
+		}
+	}
+	return gateAddr, nil
+}
+
+//FindSyscallRet returns the address of the nth (counting from 0) syscall; ret in the .text section of the loaded ntdll, for building your own indirect call schemes. Every stub has one, so picking something other than the first spreads calls around (or avoids one that's known to be watched). An error wrapping ErrGateNotFound is returned if there aren't that many.
+func FindSyscallRet(n int) (uintptr, error) {
+	if n < 0 {
+		return 0, fmt.Errorf("%w: negative occurrence %d", ErrGateNotFound, n)
+	}
+	start, size := GetNtdllStart()
+	if start == 0 {
+		return 0, fmt.Errorf("%w: ntdll", ErrModuleNotFound)
+	}
+	p, e := parsePE(start, size)
+	if e != nil {
+		return 0, e
+	}
+	text := p.Section(".text")
+	if text == nil {
+		return 0, fmt.Errorf("%w: ntdll has no .text section", ErrGateNotFound)
+	}
+	b := memorySlice(start+uintptr(text.VirtualAddress), uintptr(text.VirtualSize))
+	found := 0
+	for off := 0; ; {
+		i := bytes.Index(b[off:], syscallRet)
+		if i < 0 {
+			return 0, fmt.Errorf("%w: ntdll .text has %d, wanted number %d", ErrGateNotFound, found, n)
+		}
+		if found == n {
+			return start + uintptr(text.VirtualAddress) + uintptr(off+i), nil
+		}
+		found++
+		off += i + 1
+	}
+}
@@ -0,0 +1,161 @@
+package bananaphone
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/C-Sto/BananaPhone/pkg/BananaPhone/ntconst"
+)
+
+const (
+	systemExtendedHandleInformation = 64
+	objectNameInformation           = 1
+	objectTypeInformation           = 2
+	statusInfoLengthMismatch        = ntconst.STATUS_INFO_LENGTH_MISMATCH
+)
+
+//SystemHandle is a single entry from the system handle table (SYSTEM_HANDLE_TABLE_ENTRY_INFO_EX).
+type SystemHandle struct {
+	Object                uintptr
+	UniqueProcessID       uintptr
+	HandleValue           uintptr
+	GrantedAccess         uint32
+	CreatorBackTraceIndex uint16
+	ObjectTypeIndex       uint16
+	HandleAttributes      uint32
+	Reserved              uint32
+}
+
+//systemHandleInformationEx is the header returned by NtQuerySystemInformation(SystemExtendedHandleInformation). The handle entries follow it directly in memory.
+type systemHandleInformationEx struct {
+	NumberOfHandles uintptr
+	Reserved        uintptr
+}
+
+//ListHandles enumerates the open handles on the system using NtQuerySystemInformation(SystemExtendedHandleInformation), and returns those owned by the provided pid. A pid of 0 returns every handle on the system.
+func (b *BananaPhone) ListHandles(pid uint32) ([]SystemHandle, error) {
+	sysid, e := b.GetSysID("NtQuerySystemInformation")
+	if e != nil {
+		return nil, e
+	}
+
+	buf, e := b.querySystemInformation(sysid, systemExtendedHandleInformation, 0x10000)
+	if e != nil {
+		return nil, e
+	}
+
+	hdr := (*systemHandleInformationEx)(unsafe.Pointer(&buf[0]))
+	entrySize := unsafe.Sizeof(SystemHandle{})
+	first := unsafe.Sizeof(systemHandleInformationEx{})
+	ret := []SystemHandle{}
+	for i := uintptr(0); i < hdr.NumberOfHandles; i++ {
+		off := first + i*entrySize
+		if off+entrySize > uintptr(len(buf)) {
+			break
+		}
+		h := *(*SystemHandle)(unsafe.Pointer(&buf[off]))
+		if pid != 0 && h.UniqueProcessID != uintptr(pid) {
+			continue
+		}
+		ret = append(ret, h)
+	}
+	return ret, nil
+}
+
+//limits for querySystemInformation's buffer. The handle table of a busy box runs to tens of megabytes, anything past these means the kernel and us disagree about something.
+const (
+	maxSystemInformationSize    = 1 << 28
+	maxSystemInformationRetries = 16
+)
+
+//querySystemInformation calls NtQuerySystemInformation with the provided class, growing the buffer until it is large enough to hold the result (within maxSystemInformationSize and maxSystemInformationRetries).
+func (b *BananaPhone) querySystemInformation(sysid uint16, class uintptr, size int) ([]byte, error) {
+	for try := 0; ; try++ {
+		buf := make([]byte, size)
+		var retlen uint32
+		var pin Pinner
+		pin.Pin(&buf[0])
+		pin.Pin(&retlen)
+		r, e := b.Syscall(
+			sysid,
+			class,
+			uintptr(unsafe.Pointer(&buf[0])),
+			uintptr(len(buf)),
+			uintptr(unsafe.Pointer(&retlen)),
+		)
+		pin.Unpin()
+		if r == statusInfoLengthMismatch {
+			//the table can grow between calls, so leave a bit of headroom
+			if int(retlen) > size {
+				size = int(retlen) + 0x1000
+			} else {
+				size *= 2
+			}
+			if size > maxSystemInformationSize || try+1 >= maxSystemInformationRetries {
+				return nil, fmt.Errorf("NtQuerySystemInformation(%d) failed: buffer still too small after %d tries (%d bytes wanted): %w", class, try+1, size, NTStatus(r))
+			}
+			continue
+		}
+		if e != nil {
+			return nil, fmt.Errorf("NtQuerySystemInformation(%d) failed: %w", class, NTStatus(r))
+		}
+		return buf, nil
+	}
+}
+
+//QueryObjectName returns the name of the object a handle in this process refers to, using NtQueryObject(ObjectNameInformation), eg \Device\HarddiskVolume3\Windows\System32\lsass.exe for a file. Unnamed objects return an empty string. Beware that asking for the name of some synchronous pipe handles blocks until the pipe is read from, so don't do this blind on every handle from ListHandles on the goroutine you care about.
+func (b *BananaPhone) QueryObjectName(handle uintptr) (string, error) {
+	return b.queryObjectString(handle, objectNameInformation)
+}
+
+//QueryObjectType returns the type name of the object a handle in this process refers to (eg File, Process, Key, Section), using NtQueryObject(ObjectTypeInformation).
+func (b *BananaPhone) QueryObjectType(handle uintptr) (string, error) {
+	return b.queryObjectString(handle, objectTypeInformation)
+}
+
+//limits for queryObjectString's buffer. A UNICODE_STRING tops out at 64KiB of characters, so anything past that plus some room for the header is bogus.
+const (
+	maxObjectInfoSize    = 64<<10 + 0x200
+	maxObjectInfoRetries = 8
+)
+
+//queryObjectString calls NtQueryObject with an information class whose result starts with a UNICODE_STRING (as both OBJECT_NAME_INFORMATION and the type information do), growing the buffer until it fits (within maxObjectInfoSize and maxObjectInfoRetries), and returns the string.
+func (b *BananaPhone) queryObjectString(handle uintptr, class uintptr) (string, error) {
+	sysid, e := b.GetSysID("NtQueryObject")
+	if e != nil {
+		return "", e
+	}
+	size := 0x200
+	for try := 0; ; try++ {
+		buf := make([]byte, size)
+		var retlen uint32
+		var pin Pinner
+		pin.Pin(&buf[0])
+		pin.Pin(&retlen)
+		r, _ := b.Syscall(
+			sysid,
+			handle,
+			class,
+			uintptr(unsafe.Pointer(&buf[0])),
+			uintptr(len(buf)),
+			uintptr(unsafe.Pointer(&retlen)),
+		)
+		pin.Unpin()
+		switch r {
+		case statusInfoLengthMismatch, ntconst.STATUS_BUFFER_OVERFLOW, ntconst.STATUS_BUFFER_TOO_SMALL:
+			if int(retlen) > size {
+				size = int(retlen)
+			} else {
+				size *= 2
+			}
+			if size > maxObjectInfoSize || try+1 >= maxObjectInfoRetries {
+				return "", fmt.Errorf("NtQueryObject failed: buffer still too small after %d tries (%d bytes wanted): %w", try+1, size, NTStatus(r))
+			}
+			continue
+		}
+		if !NTStatus(r).IsSuccess() {
+			return "", fmt.Errorf("NtQueryObject failed: %w", NTStatus(r))
+		}
+		return (*UnicodeString)(unsafe.Pointer(&buf[0])).String(), nil
+	}
+}
@@ -0,0 +1,220 @@
+package bananaphone
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"unsafe"
+
+	"github.com/C-Sto/BananaPhone/pkg/BananaPhone/ntconst"
+)
+
+//hardEntrySize is the size of each entry in a hardened table: name offset (uint32), name length (uint16), sysid (uint16).
+const hardEntrySize = 8
+
+//hardTable is the read-only copy of a phone's sysids (and section snapshot) made by Harden. The entries are sorted by name so they can be binary searched in place, and the names follow them.
+type hardTable struct {
+	base, size uintptr
+	count      int
+	textOff    uintptr
+	textLen    int
+}
+
+//Harden moves the phone's resolved sysids, and the section snapshot stubs are read from, out of the Go heap into their own allocation and makes it read-only with NtProtectVirtualMemory. Anything in the process that tries to scribble on the numbers (by accident or otherwise) faults instead of sending the next syscall somewhere else. Resolve everything you need first: while the phone is hardened, sysids that aren't in the table are still resolved, but not cached. The allocation, and the sysids used to manage it, are resolved before the table is built so that Unharden never has to resolve anything. InvalidateCache, Refresh and Close all unharden (throwing the table away).
+func (b *BananaPhone) Harden() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.hard != nil {
+		return nil
+	}
+	if b.banana == nil {
+		return errors.New("bananaphone has been closed")
+	}
+	var ids [3]uint16
+	for i, n := range []string{"NtAllocateVirtualMemory", "NtProtectVirtualMemory", "NtFreeVirtualMemory"} {
+		r, e := b.getSysIDDetailed(n)
+		if e != nil {
+			return fmt.Errorf("hardening: %w", e)
+		}
+		ids[i] = r.SysID
+	}
+
+	names := make([]string, 0, len(b.ssnCache))
+	size := 0
+	for n := range b.ssnCache {
+		names = append(names, n)
+		size += hardEntrySize + len(n)
+	}
+	sort.Strings(names)
+	textOff := (size + 15) &^ 15
+	size = textOff + len(b.text)
+
+	base, e := b.allocate(ids[0], uintptr(size))
+	if e != nil {
+		return fmt.Errorf("hardening: %w", e)
+	}
+	mem := memorySlice(base, uintptr(size))
+	off := len(names) * hardEntrySize
+	for i, n := range names {
+		ent := mem[i*hardEntrySize:]
+		binary.LittleEndian.PutUint32(ent, uint32(off))
+		binary.LittleEndian.PutUint16(ent[4:], uint16(len(n)))
+		binary.LittleEndian.PutUint16(ent[6:], b.ssnCache[n])
+		off += copy(mem[off:], n)
+	}
+	copy(mem[textOff:], b.text)
+	if _, e := b.protect(ids[1], base, uintptr(size), ntconst.PAGE_READONLY); e != nil {
+		b.free(ids[2], base)
+		return fmt.Errorf("hardening: %w", e)
+	}
+
+	b.hard = &hardTable{base: base, size: uintptr(size), count: len(names), textOff: uintptr(textOff), textLen: len(b.text)}
+	for n := range b.ssnCache {
+		delete(b.ssnCache, n)
+	}
+	b.ssnCache = nil
+	if b.text != nil {
+		for i := range b.text {
+			b.text[i] = 0
+		}
+		b.text = memorySlice(base+uintptr(textOff), uintptr(len(b.text)))
+	}
+	return nil
+}
+
+//Unharden puts the sysids (and section snapshot) back on the heap where they can be updated, and releases the read-only copy. It does nothing if the phone isn't hardened.
+func (b *BananaPhone) Unharden() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.unharden(true)
+}
+
+//IsHardened reports whether Harden is in effect.
+func (b *BananaPhone) IsHardened() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.hard != nil
+}
+
+//unharden releases the hardened table, copying what's in it back onto the heap if keep is set. Callers must hold b.mu.
+func (b *BananaPhone) unharden(keep bool) error {
+	h := b.hard
+	if h == nil {
+		return nil
+	}
+	protectID, _ := h.lookup("NtProtectVirtualMemory")
+	freeID, _ := h.lookup("NtFreeVirtualMemory")
+	if keep {
+		mem := memorySlice(h.base, h.size)
+		b.ssnCache = make(map[string]uint16, h.count)
+		for i := 0; i < h.count; i++ {
+			n, id := h.entry(mem, i)
+			b.ssnCache[n] = id
+		}
+	}
+	hardText := len(b.text) > 0 && uintptr(unsafe.Pointer(&b.text[0])) == h.base+h.textOff
+	if hardText {
+		b.text = nil
+		if keep {
+			b.text = append([]byte(nil), memorySlice(h.base+h.textOff, uintptr(h.textLen))...)
+		}
+	}
+	b.hard = nil
+	//make it writable again so the sysids can be wiped before the memory goes back
+	if _, e := b.protect(protectID, h.base, h.size, ntconst.PAGE_READWRITE); e != nil {
+		return fmt.Errorf("unhardening: %w", e)
+	}
+	mem := memorySlice(h.base, h.size)
+	for i := range mem {
+		mem[i] = 0
+	}
+	return b.free(freeID, h.base)
+}
+
+//lookup binary searches the table for name.
+func (h *hardTable) lookup(name string) (uint16, bool) {
+	mem := memorySlice(h.base, h.size)
+	i := sort.Search(h.count, func(i int) bool {
+		n, _ := h.entry(mem, i)
+		return n >= name
+	})
+	if i < h.count {
+		if n, id := h.entry(mem, i); n == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+//entry returns the name and sysid of entry i.
+func (h *hardTable) entry(mem []byte, i int) (string, uint16) {
+	ent := mem[i*hardEntrySize:]
+	off := binary.LittleEndian.Uint32(ent)
+	l := binary.LittleEndian.Uint16(ent[4:])
+	return string(mem[off : off+uint32(l)]), binary.LittleEndian.Uint16(ent[6:])
+}
+
+//allocate commits size bytes of read/write memory in this process with NtAllocateVirtualMemory.
+func (b *BananaPhone) allocate(sysid uint16, size uintptr) (uintptr, error) {
+	var base uintptr
+	var pin Pinner
+	defer pin.Unpin()
+	pin.Pin(&base)
+	pin.Pin(&size)
+	r, e := b.Syscall(
+		sysid,
+		ntconst.CurrentProcess,
+		uintptr(unsafe.Pointer(&base)),
+		0,
+		uintptr(unsafe.Pointer(&size)),
+		ntconst.MEM_COMMIT|ntconst.MEM_RESERVE,
+		ntconst.PAGE_READWRITE,
+	)
+	if e != nil {
+		return 0, fmt.Errorf("NtAllocateVirtualMemory failed: %w", NTStatus(r))
+	}
+	return base, nil
+}
+
+//protect is ProtectMemory on this process with an already resolved sysid, for when b.mu is held.
+func (b *BananaPhone) protect(sysid uint16, addr, size uintptr, protect uint32) (uint32, error) {
+	var old uint32
+	var pin Pinner
+	defer pin.Unpin()
+	pin.Pin(&addr)
+	pin.Pin(&size)
+	pin.Pin(&old)
+	r, e := b.Syscall(
+		sysid,
+		ntconst.CurrentProcess,
+		uintptr(unsafe.Pointer(&addr)),
+		uintptr(unsafe.Pointer(&size)),
+		uintptr(protect),
+		uintptr(unsafe.Pointer(&old)),
+	)
+	if e != nil {
+		return 0, fmt.Errorf("NtProtectVirtualMemory failed: %w", NTStatus(r))
+	}
+	return old, nil
+}
+
+//free releases an allocation made by allocate.
+func (b *BananaPhone) free(sysid uint16, addr uintptr) error {
+	var size uintptr
+	var pin Pinner
+	defer pin.Unpin()
+	pin.Pin(&addr)
+	pin.Pin(&size)
+	r, e := b.Syscall(
+		sysid,
+		ntconst.CurrentProcess,
+		uintptr(unsafe.Pointer(&addr)),
+		uintptr(unsafe.Pointer(&size)),
+		ntconst.MEM_RELEASE,
+	)
+	if e != nil {
+		return fmt.Errorf("NtFreeVirtualMemory failed: %w", NTStatus(r))
+	}
+	return nil
+}
@@ -3,80 +3,102 @@ package bananaphone
 import (
 	"bytes"
 	"encoding/binary"
-	"errors"
+	"fmt"
+	"reflect"
+	"unicode/utf16"
 	"unsafe"
 
 	"github.com/Binject/debug/pe"
-	"github.com/awgh/rawreader"
-	"golang.org/x/sys/windows"
 )
 
-//rvaToOffset converts an RVA value from a PE file into the file offset. When using binject/debug, this should work fine even with in-memory files.
-func rvaToOffset(pefile *pe.File, rva uint32) uint32 {
+//sectionForRVA returns the section that contains the provided RVA, or nil if there isn't one.
+func sectionForRVA(pefile *pe.File, rva uint32) *pe.Section {
 	for _, hdr := range pefile.Sections {
-		baseoffset := uint64(rva)
-		if baseoffset > uint64(hdr.VirtualAddress) &&
-			baseoffset < uint64(hdr.VirtualAddress+hdr.VirtualSize) {
-			return rva - hdr.VirtualAddress + hdr.Offset
+		if rva >= hdr.VirtualAddress && rva-hdr.VirtualAddress < hdr.VirtualSize {
+			return hdr
 		}
 	}
-	return rva
+	return nil
+}
+
+//readRVA reads n bytes at the provided RVA straight from the section, rather than copying the whole image out with Bytes(). When using binject/debug, this works for both in-memory and on-disk files.
+func readRVA(pefile *pe.File, rva uint32, n int) ([]byte, error) {
+	s := sectionForRVA(pefile, rva)
+	if s == nil {
+		return nil, fmt.Errorf("rva %x is not in any section", rva)
+	}
+	buff := make([]byte, n)
+	read, e := s.ReadAt(buff, int64(rva-s.VirtualAddress))
+	if read < n {
+		return nil, fmt.Errorf("short read at rva %x: %w", rva, e)
+	}
+	return buff, nil
 }
 
 //getSysIDFromMemory takes values to resolve, and resolves in-memory.
 func getSysIDFromMemory(funcname string, ord uint32, useOrd bool) (uint16, error) {
 	start, size := GetNtdllStart()
-	rr := rawreader.New(start, int(size))
-	p, e := pe.NewFileFromMemory(rr)
+	p, e := parsePE(start, size)
 	if e != nil {
 		return 0, e
 	}
 
-	ex, e := p.Exports()
+	ex, e := peExports(p)
 	if e != nil {
 		return 0, e
 	}
 
 	for _, exp := range ex {
-		if (useOrd && exp.Ordinal == ord) || // many bothans died for this feature
-			exp.Name == funcname {
-			offset := rvaToOffset(p, exp.VirtualAddress)
-			b, e := p.Bytes()
+		if exportMatches(exp, funcname, ord, useOrd) {
+			buff, e := readRVA(p, exp.VirtualAddress, 10)
 			if e != nil {
 				return 0, e
 			}
-			buff := b[offset : offset+10]
 
 			return sysIDFromRawBytes(buff)
 		}
 	}
-	return 0, errors.New("Could not find syscall ID")
+	if useOrd {
+		return 0, fmt.Errorf("%w: ordinal %d", ErrFunctionNotFound, ord)
+	}
+	return 0, fmt.Errorf("%w: %s", ErrFunctionNotFound, funcname)
 }
 
 //getSysIDFromMemory takes values to resolve, and resolves from disk.
 func getSysIDFromDisk(funcname string, ord uint32, useOrd bool) (uint16, error) {
 	l := `C:\Windows\system32\ntdll.dll`
-	p, e := pe.Open(l)
-
+	p, e := openPE(l)
 	if e != nil {
 		return 0, e
 	}
+	defer p.Close()
 
-	ex, e := p.Exports()
+	ex, e := peExports(p)
+	if e != nil {
+		return 0, e
+	}
 	for _, exp := range ex {
-		if (useOrd && exp.Ordinal == ord) || // many bothans died for this feature
-			exp.Name == funcname {
-			offset := rvaToOffset(p, exp.VirtualAddress)
-			b, e := p.Bytes()
+		if exportMatches(exp, funcname, ord, useOrd) {
+			buff, e := readRVA(p, exp.VirtualAddress, 10)
 			if e != nil {
 				return 0, e
 			}
-			buff := b[offset : offset+10]
 
 			return sysIDFromRawBytes(buff)
 		}
 	}
-	return 0, errors.New("Could not find syscall ID")
+	if useOrd {
+		return 0, fmt.Errorf("%w: ordinal %d", ErrFunctionNotFound, ord)
+	}
+	return 0, fmt.Errorf("%w: %s", ErrFunctionNotFound, funcname)
+}
+
+//exportMatches returns true if exp is the export being looked for - by ordinal if useOrd is set (many bothans died for this feature), otherwise by name. An ordinal lookup has no name, so it mustn't fall back to matching on one: plenty of exports don't have a name either.
+func exportMatches(exp pe.Export, funcname string, ord uint32, useOrd bool) bool {
+	if useOrd {
+		return exp.Ordinal == ord
+	}
+	return exp.Name == funcname
 }
 
 //sysIDFromRawBytes takes a byte slice and determines if there is a sysID in the expected location. Returns a MayBeHookedError if the signature does not match.
@@ -87,6 +109,41 @@ func sysIDFromRawBytes(b []byte) (uint16, error) {
 	return binary.LittleEndian.Uint16(b[4:8]), nil
 }
 
+//stubFormat describes the layout of the syscall stubs in ntdll, which differs between builds. Halo's gate needs this to walk from one stub to the next.
+type stubFormat struct {
+	size          uintptr //distance between the start of two stubs
+	syscallOffset uintptr //offset of the syscall; ret instruction from the start of a stub
+}
+
+//nextStubDistance is the distance from a syscall; ret instruction to the start of the following stub.
+func (s stubFormat) nextStubDistance() uintptr {
+	return s.size - s.syscallOffset
+}
+
+//syscallRet is the syscall; ret pair every stub has, however the start of it has been patched.
+var syscallRet = []byte{0x0f, 0x05, 0xc3}
+
+//isStub returns true if b (the start of an export) looks like a syscall stub. A clean stub starts with HookCheck. A hooked one has had its first few bytes replaced with a jump, but the syscall; ret further in is left alone, whereas a regular function (eg RtlCopyMemory) won't have one.
+func (s stubFormat) isStub(b []byte) bool {
+	if bytes.HasPrefix(b, HookCheck) {
+		return true
+	}
+	if uintptr(len(b)) > s.size {
+		b = b[:s.size]
+	}
+	return bytes.Contains(b, syscallRet)
+}
+
+//stubFormatForBuild picks the stub layout for the provided Windows version.
+func stubFormatForBuild(major, build uint32) stubFormat {
+	if major < 10 || build < 10586 {
+		//mov r10, rcx; mov eax, sysid; syscall; ret; (padding)
+		return stubFormat{size: 0x10, syscallOffset: 0x08}
+	}
+	//mov r10, rcx; mov eax, sysid; test byte ptr [SharedUserData+0x308], 1; jne int2e; syscall; ret; int 2e; ret; (padding)
+	return stubFormat{size: 0x20, syscallOffset: 0x12}
+}
+
 //stupidstring is the stupid internal windows definiton of a unicode string. I hate it.
 type stupidstring struct {
 	Length    uint16
@@ -94,27 +151,53 @@ type stupidstring struct {
 	PWstr     *uint16
 }
 
+//String copies the string out. Only Length bytes are read - the buffer isn't always null terminated.
 func (s stupidstring) String() string {
-	return windows.UTF16PtrToString(s.PWstr)
-}
-
-//findSyscallRet iterates over the Ntdll memory to find a syscall; ret instruction
-func findSyscallRet() uintptr {
-	start, size := GetNtdllStart()
-	for i := start; i < start+size; i += 3 {
-		buf := make([]byte, 3)
-		unsafeReadMemory(i, buf)
-		if buf[0] == byte('\x0f') && buf[1] == byte('\x05') && buf[2] == byte('\xc3') {
-			return i
-		}
+	if s.PWstr == nil || s.Length < 2 {
+		return ""
 	}
-	return 0
+	return string(utf16.Decode(uint16Slice(uintptr(unsafe.Pointer(s.PWstr)), int(s.Length/2))))
 }
 
 //unsafeReadMemory read the memory and fill the buffer
 func unsafeReadMemory(ptr uintptr, out []byte) error {
-	for i := range out {
-		out[i] = *(*byte)(unsafe.Pointer(ptr + uintptr(i)))
-	}
+	copy(out, memorySlice(ptr, uintptr(len(out))))
 	return nil
 }
+
+//uintptrToPointer converts an address that isn't managed by the go runtime (eg, something inside a loaded module) into an unsafe.Pointer. Going through memory rather than converting directly keeps vet and -d=checkptr quiet, they both assume a uintptr was once a go pointer.
+func uintptrToPointer(addr uintptr) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&addr))
+}
+
+//memorySlice returns a byte slice over memory not managed by the go runtime (eg, a loaded module). Nothing is copied, so be careful with it. The slice header is filled in directly rather than slicing a huge array pointer, which -d=checkptr rightly complains about when the array would run off the end of an allocation.
+func memorySlice(addr, size uintptr) []byte {
+	var ret []byte
+	h := (*reflect.SliceHeader)(unsafe.Pointer(&ret))
+	h.Data = addr
+	h.Len = int(size)
+	h.Cap = int(size)
+	return ret
+}
+
+//uint16Slice is memorySlice for n uint16s, for reading UTF-16 strings in place.
+func uint16Slice(addr uintptr, n int) []uint16 {
+	var ret []uint16
+	h := (*reflect.SliceHeader)(unsafe.Pointer(&ret))
+	h.Data = addr
+	h.Len = n
+	h.Cap = n
+	return ret
+}
+
+//utf16PtrToStringLen reads a null terminated UTF-16 string from ptr, returning it and its length in uint16s (not including the terminator).
+func utf16PtrToStringLen(ptr uintptr) (string, int) {
+	n := 0
+	for *(*uint16)(uintptrToPointer(ptr + uintptr(n)*2)) != 0 {
+		n++
+	}
+	if n == 0 {
+		return "", 0
+	}
+	return string(utf16.Decode(uint16Slice(ptr, n))), n
+}
@@ -0,0 +1,40 @@
+package bananaphone
+
+import (
+	"testing"
+
+	"github.com/Binject/debug/pe"
+)
+
+func TestExportMatches(t *testing.T) {
+	exports := []pe.Export{
+		{Ordinal: 1},
+		{Ordinal: 2, Name: "NtClose"},
+		{Ordinal: 3},
+	}
+	find := func(funcname string, ord uint32, useOrd bool) (pe.Export, bool) {
+		for _, exp := range exports {
+			if exportMatches(exp, funcname, ord, useOrd) {
+				return exp, true
+			}
+		}
+		return pe.Export{}, false
+	}
+
+	//an ordinal lookup has an empty name, which the unnamed export at ordinal 1 also has
+	if exp, ok := find("", 3, true); !ok || exp.Ordinal != 3 {
+		t.Errorf("ordinal 3: got %+v, %v", exp, ok)
+	}
+	if exp, ok := find("", 2, true); !ok || exp.Name != "NtClose" {
+		t.Errorf("ordinal 2: got %+v, %v", exp, ok)
+	}
+	if exp, ok := find("NtClose", 0, false); !ok || exp.Ordinal != 2 {
+		t.Errorf("NtClose: got %+v, %v", exp, ok)
+	}
+	if exp, ok := find("", 4, true); ok {
+		t.Errorf("ordinal 4: got %+v, want no match", exp)
+	}
+	if exp, ok := find("NtOpenProcess", 2, false); ok {
+		t.Errorf("NtOpenProcess: got %+v, want no match", exp)
+	}
+}
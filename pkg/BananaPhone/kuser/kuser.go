@@ -0,0 +1,109 @@
+//go:build windows
+// +build windows
+
+//Package kuser reads values out of KUSER_SHARED_DATA, the page the kernel maps read-only into every process at 0x7FFE0000. Reading it needs no syscalls and no API calls, it's just memory.
+package kuser
+
+import (
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+//Address is where KUSER_SHARED_DATA lives in every (x86 and x64) user mode process.
+const Address = uintptr(0x7FFE0000)
+
+//offsets into KUSER_SHARED_DATA
+const (
+	offTickCountMultiplier = 0x004
+	offInterruptTime       = 0x008
+	offSystemTime          = 0x014
+	offNtSystemRoot        = 0x030
+	offNtBuildNumber       = 0x260
+	offNtProductType       = 0x264
+	offNtMajorVersion      = 0x26c
+	offNtMinorVersion      = 0x270
+	offKdDebuggerEnabled   = 0x2d4
+	offSafeBootMode        = 0x2ec
+	offTickCount           = 0x320
+)
+
+//ptr returns a pointer into the shared page. The page isn't part of the go heap, so this is fine.
+func ptr(off uintptr) unsafe.Pointer {
+	addr := Address + off
+	return *(*unsafe.Pointer)(unsafe.Pointer(&addr))
+}
+
+func readUint32(off uintptr) uint32 {
+	return *(*uint32)(ptr(off))
+}
+
+//readSystemTime reads a KSYSTEM_TIME. The kernel writes High2Time, LowPart, then High1Time, so we spin until the two high parts agree to avoid a torn read.
+func readSystemTime(off uintptr) uint64 {
+	for {
+		high1 := *(*uint32)(ptr(off + 4))
+		low := *(*uint32)(ptr(off))
+		high2 := *(*uint32)(ptr(off + 8))
+		if high1 == high2 {
+			return uint64(high1)<<32 | uint64(low)
+		}
+	}
+}
+
+//NtMajorVersion returns the major version of the running kernel (eg 10).
+func NtMajorVersion() uint32 {
+	return readUint32(offNtMajorVersion)
+}
+
+//NtMinorVersion returns the minor version of the running kernel.
+func NtMinorVersion() uint32 {
+	return readUint32(offNtMinorVersion)
+}
+
+//NtBuildNumber returns the build number of the running kernel (eg 19045). This field was only added in Windows 10, older versions return 0.
+func NtBuildNumber() uint32 {
+	return readUint32(offNtBuildNumber)
+}
+
+//NtProductType returns the product type (1 workstation, 2 domain controller, 3 server).
+func NtProductType() uint32 {
+	return readUint32(offNtProductType)
+}
+
+//NtSystemRoot returns the system root (eg C:\Windows).
+func NtSystemRoot() string {
+	return windows.UTF16ToString((*[260]uint16)(ptr(offNtSystemRoot))[:])
+}
+
+//SystemTime returns the current system time as kept by the kernel. It's only updated every clock tick, so don't expect better than ~15ms precision.
+func SystemTime() time.Time {
+	ft := int64(readSystemTime(offSystemTime))
+	//116444736000000000 is the number of 100ns intervals between 1601 and 1970
+	return time.Unix(0, (ft-116444736000000000)*100)
+}
+
+//InterruptTime returns the time since boot, including time spent asleep.
+func InterruptTime() time.Duration {
+	return time.Duration(readSystemTime(offInterruptTime)) * 100
+}
+
+//TickCount returns the same value as GetTickCount64, as a duration.
+func TickCount() time.Duration {
+	ticks := readSystemTime(offTickCount)
+	mult := uint64(readUint32(offTickCountMultiplier))
+	//this is how GetTickCount64 does it (the multiplier is a 8.24 fixed point number)
+	low, high := ticks&0xffffffff, ticks>>32
+	ms := (low*mult)>>24 + (high*mult)<<8
+	return time.Duration(ms) * time.Millisecond
+}
+
+//KdDebuggerEnabled returns true if a kernel debugger is enabled.
+func KdDebuggerEnabled() bool {
+	return *(*byte)(ptr(offKdDebuggerEnabled))&1 != 0
+}
+
+//SafeBootMode returns true if the system was booted into safe mode.
+func SafeBootMode() bool {
+	return *(*byte)(ptr(offSafeBootMode)) != 0
+}
@@ -0,0 +1,59 @@
+//go:build !windows
+// +build !windows
+
+package kuser
+
+import "time"
+
+//Address is where KUSER_SHARED_DATA lives in every (x86 and x64) user mode process. There's nothing there on this platform.
+const Address = uintptr(0x7FFE0000)
+
+//NtMajorVersion always returns 0 on platforms other than Windows.
+func NtMajorVersion() uint32 {
+	return 0
+}
+
+//NtMinorVersion always returns 0 on platforms other than Windows.
+func NtMinorVersion() uint32 {
+	return 0
+}
+
+//NtBuildNumber always returns 0 on platforms other than Windows.
+func NtBuildNumber() uint32 {
+	return 0
+}
+
+//NtProductType always returns 0 on platforms other than Windows.
+func NtProductType() uint32 {
+	return 0
+}
+
+//NtSystemRoot always returns an empty string on platforms other than Windows.
+func NtSystemRoot() string {
+	return ""
+}
+
+//SystemTime always returns the zero time on platforms other than Windows.
+func SystemTime() time.Time {
+	return time.Time{}
+}
+
+//InterruptTime always returns 0 on platforms other than Windows.
+func InterruptTime() time.Duration {
+	return 0
+}
+
+//TickCount always returns 0 on platforms other than Windows.
+func TickCount() time.Duration {
+	return 0
+}
+
+//KdDebuggerEnabled always returns false on platforms other than Windows.
+func KdDebuggerEnabled() bool {
+	return false
+}
+
+//SafeBootMode always returns false on platforms other than Windows.
+func SafeBootMode() bool {
+	return false
+}
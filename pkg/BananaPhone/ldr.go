@@ -1,19 +1,91 @@
 package bananaphone
 
+import (
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+//LdrDataTableEntry is the loader's per-module record (LDR_DATA_TABLE_ENTRY) as laid out on x64 Windows 8 and later. Fields after TimeDateStamp don't exist on older versions, so don't go reading them on Windows 7. The pointers in here point at live loader memory - use Info to copy the interesting bits out.
 type LdrDataTableEntry struct {
-	InLoadOrderLinks           ListEntry
-	InMemoryOrderLinks         ListEntry
-	InInitializationOrderLinks ListEntry
-	DllBase                    *uintptr
-	EntryPoint                 *uintptr
-	SizeOfImage                *uintptr
-	FullDllName                stupidstring
-	BaseDllName                stupidstring
-	Flags                      uint32
-	LoadCount                  uint16
-	TlsIndex                   uint16
-	HashLinks                  ListEntry
-	TimeDateStamp              uint64
+	InLoadOrderLinks            ListEntry
+	InMemoryOrderLinks          ListEntry
+	InInitializationOrderLinks  ListEntry
+	DllBase                     uintptr
+	EntryPoint                  uintptr
+	SizeOfImage                 uint32
+	_                           uint32
+	FullDllName                 UnicodeString
+	BaseDllName                 UnicodeString
+	Flags                       uint32
+	LoadCount                   uint16 //ObsoleteLoadCount on newer versions, it's always -1 for static loads
+	TlsIndex                    uint16
+	HashLinks                   ListEntry
+	TimeDateStamp               uint32
+	_                           uint32
+	EntryPointActivationContext uintptr
+	Lock                        uintptr
+	DdagNode                    uintptr
+	NodeModuleLink              ListEntry
+	LoadContext                 uintptr
+	ParentDllBase               uintptr
+	SwitchBackContext           uintptr
+	BaseAddressIndexNode        [3]uintptr //RTL_BALANCED_NODE
+	MappingInfoIndexNode        [3]uintptr //RTL_BALANCED_NODE
+	OriginalBase                uintptr
+	LoadTime                    int64 //FILETIME (LARGE_INTEGER)
+	BaseNameHashValue           uint32
+	LoadReason                  uint32
+}
+
+//ModuleInfo is the useful bits of a loader data table entry, copied out of the PEB so you don't have to go poking at pointers.
+type ModuleInfo struct {
+	BaseAddr      uintptr
+	Size          uintptr
+	EntryPoint    uintptr
+	FullPath      string
+	BaseName      string
+	TimeDateStamp uint32
+	LoadCount     uint16
+	Flags         uint32
+	LoadTime      time.Time
+}
+
+//Info copies the useful fields out of the entry into a ModuleInfo, so nothing refers back into the loader's memory.
+func (e *LdrDataTableEntry) Info() ModuleInfo {
+	return ModuleInfo{
+		BaseAddr:      e.DllBase,
+		Size:          uintptr(e.SizeOfImage),
+		EntryPoint:    e.EntryPoint,
+		FullPath:      e.FullDllName.String(),
+		BaseName:      e.BaseDllName.String(),
+		TimeDateStamp: e.TimeDateStamp,
+		LoadCount:     e.LoadCount,
+		Flags:         e.Flags,
+		LoadTime:      filetimeToTime(e.LoadTime),
+	}
+}
+
+//filetimeToTime converts a FILETIME (100ns intervals since 1601) into a time.Time.
+func filetimeToTime(ft int64) time.Time {
+	if ft == 0 {
+		return time.Time{}
+	}
+	//116444736000000000 is the number of 100ns intervals between 1601 and 1970
+	return time.Unix(0, (ft-116444736000000000)*100)
+}
+
+//Image contains info about a loaded image. Literally just a Base Addr and a Size - it should allow someone with a handy PE parser to pull the image out of memory... (or see CarveModule, which does exactly that)
+type Image struct {
+	BaseAddr uint64
+	Size     uint64
+}
+
+//ModuleHash returns the hash used by GetModuleBaseByHash - 32 bit FNV-1a of the lower case base name (eg "ntdll.dll"). Precompute these with the same function.
+func ModuleHash(name string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(name)))
+	return h.Sum32()
 }
 
 type ListEntry struct {
@@ -45,15 +117,20 @@ typedef struct _LDR_DATA_TABLE_ENTRY
                ULONG CheckSum;
           };
      };
-     union
-     {
-          ULONG TimeDateStamp;
-          PVOID LoadedImports;
-     };
+     ULONG TimeDateStamp;
      _ACTIVATION_CONTEXT * EntryPointActivationContext;
-     PVOID PatchInformation;
-     LIST_ENTRY ForwarderLinks;
-     LIST_ENTRY ServiceTagLinks;
-     LIST_ENTRY StaticLinks;
+     PVOID Lock;
+     PLDR_DDAG_NODE DdagNode;
+     LIST_ENTRY NodeModuleLink;
+     PLDRP_LOAD_CONTEXT LoadContext;
+     PVOID ParentDllBase;
+     PVOID SwitchBackContext;
+     RTL_BALANCED_NODE BaseAddressIndexNode;
+     RTL_BALANCED_NODE MappingInfoIndexNode;
+     ULONG_PTR OriginalBase;
+     LARGE_INTEGER LoadTime;
+     ULONG BaseNameHashValue;
+     LDR_DLL_LOAD_REASON LoadReason;
+     ...
 } LDR_DATA_TABLE_ENTRY, *PLDR_DATA_TABLE_ENTRY;
 */
@@ -0,0 +1,245 @@
+package bananaphone
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/C-Sto/BananaPhone/pkg/BananaPhone/ntconst"
+)
+
+const memoryBasicInformationClass = 0
+
+//MemoryBasicInformation is the MEMORY_BASIC_INFORMATION structure returned by NtQueryVirtualMemory.
+type MemoryBasicInformation struct {
+	BaseAddress       uintptr
+	AllocationBase    uintptr
+	AllocationProtect uint32
+	PartitionID       uint16
+	_                 uint16
+	RegionSize        uintptr
+	State             uint32
+	Protect           uint32
+	Type              uint32
+	_                 uint32
+}
+
+//QueryMemory returns information about the region containing addr in the process referred to by handle (use ntconst.CurrentProcess for this process), using NtQueryVirtualMemory.
+func (b *BananaPhone) QueryMemory(handle, addr uintptr) (MemoryBasicInformation, error) {
+	var mbi MemoryBasicInformation
+	sysid, e := b.GetSysID("NtQueryVirtualMemory")
+	if e != nil {
+		return mbi, e
+	}
+	//pinning moves the out params to the heap, a stack growth mid call can't leave the kernel writing to the old stack
+	var retlen uintptr
+	var pin Pinner
+	defer pin.Unpin()
+	pin.Pin(&mbi)
+	pin.Pin(&retlen)
+	r, e := b.Syscall(
+		sysid,
+		handle,
+		addr,
+		memoryBasicInformationClass,
+		uintptr(unsafe.Pointer(&mbi)),
+		unsafe.Sizeof(mbi),
+		uintptr(unsafe.Pointer(&retlen)),
+	)
+	if e != nil {
+		return mbi, fmt.Errorf("NtQueryVirtualMemory failed: %w", NTStatus(r))
+	}
+	return mbi, nil
+}
+
+//EnumRegions walks the address space of the process referred to by handle (use ntconst.CurrentProcess for this process) with NtQueryVirtualMemory, returning every region from the bottom of the address space up, including free ones.
+func (b *BananaPhone) EnumRegions(handle uintptr) ([]MemoryBasicInformation, error) {
+	ret := []MemoryBasicInformation{}
+	var addr uintptr
+	for {
+		mbi, e := b.QueryMemory(handle, addr)
+		if e != nil {
+			//querying past the top of user space fails, that's how we know we're done
+			if len(ret) > 0 {
+				return ret, nil
+			}
+			return nil, e
+		}
+		ret = append(ret, mbi)
+		next := mbi.BaseAddress + mbi.RegionSize
+		if mbi.RegionSize == 0 || next <= addr {
+			return ret, nil
+		}
+		addr = next
+	}
+}
+
+//ProtectMemory changes the protection of the pages covering [addr, addr+size) in the process referred to by handle, using NtProtectVirtualMemory. The previous protection is returned.
+func (b *BananaPhone) ProtectMemory(handle, addr, size uintptr, protect uint32) (uint32, error) {
+	sysid, e := b.GetSysID("NtProtectVirtualMemory")
+	if e != nil {
+		return 0, e
+	}
+	var old uint32
+	var pin Pinner
+	defer pin.Unpin()
+	pin.Pin(&addr)
+	pin.Pin(&size)
+	pin.Pin(&old)
+	r, e := b.Syscall(
+		sysid,
+		handle,
+		uintptr(unsafe.Pointer(&addr)),
+		uintptr(unsafe.Pointer(&size)),
+		uintptr(protect),
+		uintptr(unsafe.Pointer(&old)),
+	)
+	if e != nil {
+		return 0, fmt.Errorf("NtProtectVirtualMemory failed: %w", NTStatus(r))
+	}
+	return old, nil
+}
+
+//WriteMemory writes the provided memory to the specified memory address. Does **not** check permissions, may cause panic if memory is not writable etc.
+func WriteMemory(inbuf []byte, destination uintptr) {
+	copy(memorySlice(destination, uintptr(len(inbuf))), inbuf)
+}
+
+//WriteMemoryChunked is WriteMemory, copying at most chunk bytes at a time. A single copy can't be preempted, so for big (multi megabyte) writes this gives the scheduler and GC a look in between chunks. A chunk of 0 or less copies everything at once.
+func WriteMemoryChunked(inbuf []byte, destination uintptr, chunk int) {
+	for chunk > 0 && len(inbuf) > chunk {
+		WriteMemory(inbuf[:chunk], destination)
+		inbuf = inbuf[chunk:]
+		destination += uintptr(chunk)
+	}
+	WriteMemory(inbuf, destination)
+}
+
+//ReadMemory fills outbuf with the memory at the specified address. Like WriteMemory, nothing is checked and unreadable memory will cause a panic.
+func ReadMemory(outbuf []byte, source uintptr) {
+	copy(outbuf, memorySlice(source, uintptr(len(outbuf))))
+}
+
+//ReadMemoryChunked is ReadMemory, copying at most chunk bytes at a time. See WriteMemoryChunked.
+func ReadMemoryChunked(outbuf []byte, source uintptr, chunk int) {
+	for chunk > 0 && len(outbuf) > chunk {
+		ReadMemory(outbuf[:chunk], source)
+		outbuf = outbuf[chunk:]
+		source += uintptr(chunk)
+	}
+	ReadMemory(outbuf, source)
+}
+
+//isWritable returns true if the provided page protection allows writes.
+func isWritable(protect uint32) bool {
+	if protect&(ntconst.PAGE_GUARD|ntconst.PAGE_NOACCESS) != 0 {
+		return false
+	}
+	return protect&(ntconst.PAGE_READWRITE|ntconst.PAGE_WRITECOPY|ntconst.PAGE_EXECUTE_READWRITE|ntconst.PAGE_EXECUTE_WRITECOPY) != 0
+}
+
+//isExecutable returns true if the provided page protection allows execution.
+func isExecutable(protect uint32) bool {
+	return protect&(ntconst.PAGE_EXECUTE|ntconst.PAGE_EXECUTE_READ|ntconst.PAGE_EXECUTE_READWRITE|ntconst.PAGE_EXECUTE_WRITECOPY) != 0
+}
+
+//SafeWriteMemory is WriteMemory, but it checks the destination first. Each region the write covers is queried, made writable with a direct NtProtectVirtualMemory call if it isn't already, written, and then put back to its original protection. Executable pages are made RWX rather than RW while writing, so other threads running code on the same page don't fall over. Uncommitted memory or a failed protection change returns an error instead of panicking.
+func (b *BananaPhone) SafeWriteMemory(inbuf []byte, destination uintptr) error {
+	const self = ntconst.CurrentProcess
+	end := destination + uintptr(len(inbuf))
+	for addr := destination; addr < end; {
+		mbi, e := b.QueryMemory(self, addr)
+		if e != nil {
+			return e
+		}
+		if mbi.State != ntconst.MEM_COMMIT {
+			return fmt.Errorf("memory at %x is not committed", addr)
+		}
+		chunkEnd := mbi.BaseAddress + mbi.RegionSize
+		if chunkEnd > end {
+			chunkEnd = end
+		}
+		chunk := inbuf[addr-destination : chunkEnd-destination]
+
+		if isWritable(mbi.Protect) {
+			WriteMemory(chunk, addr)
+		} else {
+			newProtect := uint32(ntconst.PAGE_READWRITE)
+			if isExecutable(mbi.Protect) {
+				newProtect = ntconst.PAGE_EXECUTE_READWRITE
+			}
+			old, e := b.ProtectMemory(self, addr, uintptr(len(chunk)), newProtect)
+			if e != nil {
+				return e
+			}
+			WriteMemory(chunk, addr)
+			if _, e = b.ProtectMemory(self, addr, uintptr(len(chunk)), old); e != nil {
+				return e
+			}
+		}
+		addr = chunkEnd
+	}
+	return nil
+}
+
+//ReadProcessMemory reads size bytes from addr in the process referred to by handle, using NtReadVirtualMemory. If only part of the range could be read (STATUS_PARTIAL_COPY), the bytes that were read are returned along with the error, which wraps the NTStatus.
+func (b *BananaPhone) ReadProcessMemory(handle, addr uintptr, size int) ([]byte, error) {
+	sysid, e := b.GetSysID("NtReadVirtualMemory")
+	if e != nil {
+		return nil, e
+	}
+	if size == 0 {
+		return []byte{}, nil
+	}
+	buf := make([]byte, size)
+	var read uintptr
+	var pin Pinner
+	defer pin.Unpin()
+	pin.Pin(&buf[0])
+	pin.Pin(&read)
+	r, e := b.Syscall(
+		sysid,
+		handle,
+		addr,
+		uintptr(unsafe.Pointer(&buf[0])),
+		uintptr(size),
+		uintptr(unsafe.Pointer(&read)),
+	)
+	if e != nil {
+		if r == ntconst.STATUS_PARTIAL_COPY {
+			return buf[:read], fmt.Errorf("partial read: got %d of %d bytes: %w", read, size, NTStatus(r))
+		}
+		return nil, fmt.Errorf("NtReadVirtualMemory failed: %w", NTStatus(r))
+	}
+	return buf[:read], nil
+}
+
+//WriteProcessMemory writes buf to addr in the process referred to by handle, using NtWriteVirtualMemory. The number of bytes written is returned, which may be less than len(buf) alongside an error on a partial write.
+func (b *BananaPhone) WriteProcessMemory(handle, addr uintptr, buf []byte) (int, error) {
+	sysid, e := b.GetSysID("NtWriteVirtualMemory")
+	if e != nil {
+		return 0, e
+	}
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	var written uintptr
+	var pin Pinner
+	defer pin.Unpin()
+	pin.Pin(&buf[0])
+	pin.Pin(&written)
+	r, e := b.Syscall(
+		sysid,
+		handle,
+		addr,
+		uintptr(unsafe.Pointer(&buf[0])),
+		uintptr(len(buf)),
+		uintptr(unsafe.Pointer(&written)),
+	)
+	if e != nil {
+		if r == ntconst.STATUS_PARTIAL_COPY {
+			return int(written), fmt.Errorf("partial write: wrote %d of %d bytes: %w", written, len(buf), NTStatus(r))
+		}
+		return int(written), fmt.Errorf("NtWriteVirtualMemory failed: %w", NTStatus(r))
+	}
+	return int(written), nil
+}
@@ -0,0 +1,76 @@
+package bananaphone
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+	"unsafe"
+)
+
+//benchMemorySize is a multi megabyte payload, the case the per byte loop was slowest at.
+const benchMemorySize = 8 << 20
+
+//writeMemoryBytewise is WriteMemory as it used to be, one byte at a time, to compare against.
+func writeMemoryBytewise(inbuf []byte, destination uintptr) {
+	for index := uint32(0); index < uint32(len(inbuf)); index++ {
+		v := (*byte)(uintptrToPointer(destination + uintptr(index)))
+		*v = inbuf[index]
+	}
+}
+
+func TestWriteReadMemoryChunked(t *testing.T) {
+	src := make([]byte, 1000)
+	for i := range src {
+		src[i] = byte(i)
+	}
+	for _, chunk := range []int{0, 1, 7, 999, 1000, 4096} {
+		dst := make([]byte, len(src))
+		WriteMemoryChunked(src, uintptr(unsafe.Pointer(&dst[0])), chunk)
+		if !bytes.Equal(dst, src) {
+			t.Errorf("WriteMemoryChunked(%d) didn't copy everything", chunk)
+		}
+		out := make([]byte, len(src))
+		ReadMemoryChunked(out, uintptr(unsafe.Pointer(&dst[0])), chunk)
+		if !bytes.Equal(out, src) {
+			t.Errorf("ReadMemoryChunked(%d) didn't copy everything", chunk)
+		}
+	}
+}
+
+func benchmarkWrite(b *testing.B, write func(inbuf []byte, destination uintptr)) {
+	src := make([]byte, benchMemorySize)
+	dst := make([]byte, benchMemorySize)
+	addr := uintptr(unsafe.Pointer(&dst[0]))
+	b.SetBytes(benchMemorySize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		write(src, addr)
+	}
+	runtime.KeepAlive(dst)
+}
+
+func BenchmarkWriteMemoryBytewise(b *testing.B) {
+	benchmarkWrite(b, writeMemoryBytewise)
+}
+
+func BenchmarkWriteMemory(b *testing.B) {
+	benchmarkWrite(b, WriteMemory)
+}
+
+func BenchmarkWriteMemoryChunked64K(b *testing.B) {
+	benchmarkWrite(b, func(inbuf []byte, destination uintptr) {
+		WriteMemoryChunked(inbuf, destination, 64<<10)
+	})
+}
+
+func BenchmarkReadMemory(b *testing.B) {
+	src := make([]byte, benchMemorySize)
+	out := make([]byte, benchMemorySize)
+	addr := uintptr(unsafe.Pointer(&src[0]))
+	b.SetBytes(benchMemorySize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ReadMemory(out, addr)
+	}
+	runtime.KeepAlive(src)
+}
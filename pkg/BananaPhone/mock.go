@@ -0,0 +1,117 @@
+package bananaphone
+
+import (
+	"fmt"
+	"sync"
+)
+
+//MockResolver is a deterministic, in-memory SyscallResolver for tests. Sysids and function pointers come from fixed maps, and every Syscall is recorded instead of being made, so it works on any platform. The zero value has no functions, use NewMockResolver or fill in the maps.
+type MockResolver struct {
+	//SysIDs maps function names to the sysid GetSysID returns.
+	SysIDs map[string]uint16
+	//FuncPtrs maps function names to the address GetFuncPtr (and NewProc) returns.
+	FuncPtrs map[string]uint64
+	//Result, if set, decides what each Syscall returns. Otherwise every call succeeds.
+	Result func(call MockSyscall) (errcode uint32, err error)
+
+	mu    sync.Mutex
+	calls []MockSyscall
+	//resolved is the name each sysid was last handed out for by GetSysID, so an Nt/Zw pair sharing a sysid records the one the caller asked for
+	resolved map[uint16]string
+}
+
+//MockSyscall is a single Syscall recorded by a MockResolver.
+type MockSyscall struct {
+	SysID uint16
+	//Name is the function the sysid was last resolved for with GetSysID. If it wasn't resolved through the mock, it's the first name (in sorted order) mapped to the sysid in SysIDs, if any.
+	Name string
+	Args []uintptr
+}
+
+var _ SyscallResolver = (*MockResolver)(nil)
+
+//NewMockResolver returns a MockResolver that resolves the provided name to sysid mappings.
+func NewMockResolver(sysids map[string]uint16) *MockResolver {
+	m := &MockResolver{
+		SysIDs:   make(map[string]uint16, len(sysids)),
+		FuncPtrs: make(map[string]uint64),
+	}
+	for k, v := range sysids {
+		m.SysIDs[k] = v
+	}
+	return m
+}
+
+//GetSysID returns the sysid mapped to funcname, or an error if there isn't one.
+func (m *MockResolver) GetSysID(funcname string) (uint16, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id, ok := m.SysIDs[funcname]; ok {
+		if m.resolved == nil {
+			m.resolved = make(map[uint16]string)
+		}
+		m.resolved[id] = funcname
+		return id, nil
+	}
+	return 0, fmt.Errorf("%w: %s", ErrFunctionNotFound, funcname)
+}
+
+//GetFuncPtr returns the address mapped to funcname, or an error if there isn't one.
+func (m *MockResolver) GetFuncPtr(funcname string) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p, ok := m.FuncPtrs[funcname]; ok {
+		return p, nil
+	}
+	return 0, fmt.Errorf("%w: %s", ErrFunctionNotFound, funcname)
+}
+
+//NewProc returns a procedure with the address mapped to funcname (0 and Err set if there isn't one, same as BananaPhone).
+func (m *MockResolver) NewProc(funcname string) BananaProcedure {
+	addr, e := m.GetFuncPtr(funcname)
+	return BananaProcedure{address: uintptr(addr), err: e}
+}
+
+//Syscall records the call and returns whatever Result says (success if it's nil). Nothing is actually called.
+func (m *MockResolver) Syscall(sysid uint16, argh ...uintptr) (errcode uint32, err error) {
+	m.mu.Lock()
+	call := MockSyscall{SysID: sysid, Args: append([]uintptr(nil), argh...)}
+	call.Name = m.nameFor(sysid)
+	m.calls = append(m.calls, call)
+	result := m.Result
+	m.mu.Unlock()
+	if result != nil {
+		return result(call)
+	}
+	return 0, nil
+}
+
+//nameFor returns the name to record for a sysid. Callers must hold m.mu.
+func (m *MockResolver) nameFor(sysid uint16) string {
+	if n, ok := m.resolved[sysid]; ok {
+		return n
+	}
+	//map order is random, so pick the smallest name rather than whichever comes up first
+	name, found := "", false
+	for k, v := range m.SysIDs {
+		if v == sysid && (!found || k < name) {
+			name, found = k, true
+		}
+	}
+	return name
+}
+
+//Calls returns a copy of every Syscall recorded so far, in order.
+func (m *MockResolver) Calls() []MockSyscall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MockSyscall(nil), m.calls...)
+}
+
+//Reset forgets the recorded calls, and which names the sysids were resolved for.
+func (m *MockResolver) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+	m.resolved = nil
+}
@@ -0,0 +1,100 @@
+//go:build windows && amd64
+// +build windows,amd64
+
+package bananaphone
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//walkModules calls fn for each module in the PEB load order list until fn returns false or the list wraps around. No API calls are made.
+func walkModules(fn func(start, size uintptr, path string) bool) {
+	for i, n := 0, GetModuleCount(); i < n; i++ {
+		s, si, p, e := GetModuleLoadedOrder(i)
+		if e != nil {
+			return
+		}
+		if p != "" && !fn(s, si, p) {
+			return
+		}
+	}
+}
+
+//matchModuleName returns true if name refers to the module at path. Full path, base name, and base name without an extension all match, case insensitively.
+func matchModuleName(path, name string) bool {
+	base := filepath.Base(path)
+	return strings.EqualFold(path, name) ||
+		strings.EqualFold(base, name) ||
+		strings.EqualFold(strings.TrimSuffix(base, filepath.Ext(base)), name)
+}
+
+//GetModuleBase is a GetModuleHandle equivalent - it returns the base address and size of a loaded module by walking the PEB, without any API calls. The name can be the full path, the base name ("kernel32.dll") or the base name without extension ("kernel32"), and is case insensitive.
+func GetModuleBase(name string) (base uintptr, size uintptr, err error) {
+	found := false
+	walkModules(func(s, si uintptr, p string) bool {
+		if matchModuleName(p, name) {
+			base, size, found = s, si, true
+			return false
+		}
+		return true
+	})
+	if !found {
+		return 0, 0, fmt.Errorf("%w: %s", ErrModuleNotFound, name)
+	}
+	return base, size, nil
+}
+
+//GetModuleBaseByHash works like GetModuleBase, but matches on the ModuleHash of the module's base name rather than a string.
+func GetModuleBaseByHash(h uint32) (base uintptr, size uintptr, err error) {
+	found := false
+	walkModules(func(s, si uintptr, p string) bool {
+		if ModuleHash(filepath.Base(p)) == h {
+			base, size, found = s, si, true
+			return false
+		}
+		return true
+	})
+	if !found {
+		return 0, 0, fmt.Errorf("%w: %08x", ErrModuleNotFound, h)
+	}
+	return base, size, nil
+}
+
+//InMemLoadsOrdered returns the loaded modules of the current process in load order (the exe first, then usually ntdll, kernel32 etc). Unlike InMemLoads, the order is kept and the rest of the loader data is included. No syscalls are made.
+func InMemLoadsOrdered() ([]ModuleInfo, error) {
+	ret := []ModuleInfo{}
+	for i, n := 0, GetModuleCount(); i < n; i++ {
+		e := GetModuleLoadedOrderPtr(i)
+		if e == nil {
+			break
+		}
+		if m := e.Info(); m.FullPath != "" {
+			ret = append(ret, m)
+		}
+	}
+	return ret, nil
+}
+
+//WaitForModule polls the PEB module list until the named module is loaded (see GetModuleBase for name matching), then returns its base and size. A timeout of 0 waits forever.
+func WaitForModule(name string, timeout time.Duration) (base uintptr, size uintptr, err error) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	for {
+		base, size, err = GetModuleBase(name)
+		if err == nil {
+			return base, size, nil
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return 0, 0, fmt.Errorf("timed out waiting for module: %s", name)
+		}
+		time.Sleep(moduleWaitInterval)
+	}
+}
+
+//moduleWaitInterval is how often WaitForModule checks the PEB.
+const moduleWaitInterval = 50 * time.Millisecond
@@ -0,0 +1,225 @@
+//Package ntconst contains the numeric constants that are commonly passed to (or returned from) Nt* functions, so that users of bananaphone.Syscall don't need to pull in golang.org/x/sys/windows just for some numbers.
+package ntconst
+
+//Handy pseudo-handles for the current process/thread.
+const (
+	CurrentProcess = ^uintptr(0)     //-1
+	CurrentThread  = ^uintptr(0) - 1 //-2
+)
+
+//Memory protection constants (PAGE_*)
+const (
+	PAGE_NOACCESS          = 0x01
+	PAGE_READONLY          = 0x02
+	PAGE_READWRITE         = 0x04
+	PAGE_WRITECOPY         = 0x08
+	PAGE_EXECUTE           = 0x10
+	PAGE_EXECUTE_READ      = 0x20
+	PAGE_EXECUTE_READWRITE = 0x40
+	PAGE_EXECUTE_WRITECOPY = 0x80
+	PAGE_GUARD             = 0x100
+	PAGE_NOCACHE           = 0x200
+	PAGE_WRITECOMBINE      = 0x400
+)
+
+//Memory allocation/free types and region states (MEM_*)
+const (
+	MEM_COMMIT      = 0x00001000
+	MEM_RESERVE     = 0x00002000
+	MEM_DECOMMIT    = 0x00004000
+	MEM_RELEASE     = 0x00008000
+	MEM_FREE        = 0x00010000
+	MEM_PRIVATE     = 0x00020000
+	MEM_MAPPED      = 0x00040000
+	MEM_RESET       = 0x00080000
+	MEM_TOP_DOWN    = 0x00100000
+	MEM_IMAGE       = 0x01000000
+	MEM_LARGE_PAGES = 0x20000000
+)
+
+//Section attributes and access (SEC_*, SECTION_*)
+const (
+	SEC_FILE      = 0x00800000
+	SEC_IMAGE     = 0x01000000
+	SEC_RESERVE   = 0x04000000
+	SEC_COMMIT    = 0x08000000
+	SEC_NOCACHE   = 0x10000000
+	SEC_NO_CHANGE = 0x00400000
+
+	SECTION_QUERY                = 0x0001
+	SECTION_MAP_WRITE            = 0x0002
+	SECTION_MAP_READ             = 0x0004
+	SECTION_MAP_EXECUTE          = 0x0008
+	SECTION_EXTEND_SIZE          = 0x0010
+	SECTION_MAP_EXECUTE_EXPLICIT = 0x0020
+	SECTION_ALL_ACCESS           = STANDARD_RIGHTS_REQUIRED | SECTION_QUERY | SECTION_MAP_WRITE | SECTION_MAP_READ | SECTION_MAP_EXECUTE | SECTION_EXTEND_SIZE
+)
+
+//Standard and generic access rights
+const (
+	DELETE                   = 0x00010000
+	READ_CONTROL             = 0x00020000
+	WRITE_DAC                = 0x00040000
+	WRITE_OWNER              = 0x00080000
+	SYNCHRONIZE              = 0x00100000
+	STANDARD_RIGHTS_REQUIRED = 0x000F0000
+	STANDARD_RIGHTS_ALL      = 0x001F0000
+	MAXIMUM_ALLOWED          = 0x02000000
+	GENERIC_READ             = 0x80000000
+	GENERIC_WRITE            = 0x40000000
+	GENERIC_EXECUTE          = 0x20000000
+	GENERIC_ALL              = 0x10000000
+)
+
+//Process access rights (PROCESS_*)
+const (
+	PROCESS_TERMINATE                 = 0x0001
+	PROCESS_CREATE_THREAD             = 0x0002
+	PROCESS_SET_SESSIONID             = 0x0004
+	PROCESS_VM_OPERATION              = 0x0008
+	PROCESS_VM_READ                   = 0x0010
+	PROCESS_VM_WRITE                  = 0x0020
+	PROCESS_DUP_HANDLE                = 0x0040
+	PROCESS_CREATE_PROCESS            = 0x0080
+	PROCESS_SET_QUOTA                 = 0x0100
+	PROCESS_SET_INFORMATION           = 0x0200
+	PROCESS_QUERY_INFORMATION         = 0x0400
+	PROCESS_SUSPEND_RESUME            = 0x0800
+	PROCESS_QUERY_LIMITED_INFORMATION = 0x1000
+	PROCESS_ALL_ACCESS                = STANDARD_RIGHTS_REQUIRED | SYNCHRONIZE | 0xFFFF
+)
+
+//Thread access rights (THREAD_*)
+const (
+	THREAD_TERMINATE                 = 0x0001
+	THREAD_SUSPEND_RESUME            = 0x0002
+	THREAD_GET_CONTEXT               = 0x0008
+	THREAD_SET_CONTEXT               = 0x0010
+	THREAD_SET_INFORMATION           = 0x0020
+	THREAD_QUERY_INFORMATION         = 0x0040
+	THREAD_SET_THREAD_TOKEN          = 0x0080
+	THREAD_IMPERSONATE               = 0x0100
+	THREAD_DIRECT_IMPERSONATION      = 0x0200
+	THREAD_SET_LIMITED_INFORMATION   = 0x0400
+	THREAD_QUERY_LIMITED_INFORMATION = 0x0800
+	THREAD_ALL_ACCESS                = STANDARD_RIGHTS_REQUIRED | SYNCHRONIZE | 0xFFFF
+)
+
+//Event, mutant and semaphore access rights (EVENT_*, MUTANT_*, SEMAPHORE_*)
+const (
+	EVENT_QUERY_STATE      = 0x0001
+	EVENT_MODIFY_STATE     = 0x0002
+	EVENT_ALL_ACCESS       = STANDARD_RIGHTS_REQUIRED | SYNCHRONIZE | 0x3
+	MUTANT_QUERY_STATE     = 0x0001
+	MUTANT_ALL_ACCESS      = STANDARD_RIGHTS_REQUIRED | SYNCHRONIZE | MUTANT_QUERY_STATE
+	SEMAPHORE_QUERY_STATE  = 0x0001
+	SEMAPHORE_MODIFY_STATE = 0x0002
+	SEMAPHORE_ALL_ACCESS   = STANDARD_RIGHTS_REQUIRED | SYNCHRONIZE | 0x3
+)
+
+//File access rights, share modes, create dispositions and create options (FILE_*)
+const (
+	FILE_READ_DATA        = 0x0001
+	FILE_WRITE_DATA       = 0x0002
+	FILE_APPEND_DATA      = 0x0004
+	FILE_READ_EA          = 0x0008
+	FILE_WRITE_EA         = 0x0010
+	FILE_EXECUTE          = 0x0020
+	FILE_READ_ATTRIBUTES  = 0x0080
+	FILE_WRITE_ATTRIBUTES = 0x0100
+	FILE_ALL_ACCESS       = STANDARD_RIGHTS_REQUIRED | SYNCHRONIZE | 0x1FF
+	FILE_GENERIC_READ     = READ_CONTROL | FILE_READ_DATA | FILE_READ_ATTRIBUTES | FILE_READ_EA | SYNCHRONIZE
+	FILE_GENERIC_WRITE    = READ_CONTROL | FILE_WRITE_DATA | FILE_WRITE_ATTRIBUTES | FILE_WRITE_EA | FILE_APPEND_DATA | SYNCHRONIZE
+
+	FILE_SHARE_READ   = 0x00000001
+	FILE_SHARE_WRITE  = 0x00000002
+	FILE_SHARE_DELETE = 0x00000004
+
+	FILE_SUPERSEDE    = 0x00000000
+	FILE_OPEN         = 0x00000001
+	FILE_CREATE       = 0x00000002
+	FILE_OPEN_IF      = 0x00000003
+	FILE_OVERWRITE    = 0x00000004
+	FILE_OVERWRITE_IF = 0x00000005
+
+	FILE_DIRECTORY_FILE          = 0x00000001
+	FILE_WRITE_THROUGH           = 0x00000002
+	FILE_SEQUENTIAL_ONLY         = 0x00000004
+	FILE_SYNCHRONOUS_IO_ALERT    = 0x00000010
+	FILE_SYNCHRONOUS_IO_NONALERT = 0x00000020
+	FILE_NON_DIRECTORY_FILE      = 0x00000040
+	FILE_DELETE_ON_CLOSE         = 0x00001000
+)
+
+//Registry key access rights (KEY_*)
+const (
+	KEY_QUERY_VALUE        = 0x0001
+	KEY_SET_VALUE          = 0x0002
+	KEY_CREATE_SUB_KEY     = 0x0004
+	KEY_ENUMERATE_SUB_KEYS = 0x0008
+	KEY_NOTIFY             = 0x0010
+	KEY_CREATE_LINK        = 0x0020
+	KEY_WOW64_64KEY        = 0x0100
+	KEY_WOW64_32KEY        = 0x0200
+	KEY_READ               = (READ_CONTROL | KEY_QUERY_VALUE | KEY_ENUMERATE_SUB_KEYS | KEY_NOTIFY) &^ SYNCHRONIZE
+	KEY_WRITE              = (READ_CONTROL | KEY_SET_VALUE | KEY_CREATE_SUB_KEY) &^ SYNCHRONIZE
+	KEY_ALL_ACCESS         = (STANDARD_RIGHTS_ALL | KEY_QUERY_VALUE | KEY_SET_VALUE | KEY_CREATE_SUB_KEY | KEY_ENUMERATE_SUB_KEYS | KEY_NOTIFY | KEY_CREATE_LINK) &^ SYNCHRONIZE
+)
+
+//Object directory access rights (DIRECTORY_*)
+const (
+	DIRECTORY_QUERY               = 0x0001
+	DIRECTORY_TRAVERSE            = 0x0002
+	DIRECTORY_CREATE_OBJECT       = 0x0004
+	DIRECTORY_CREATE_SUBDIRECTORY = 0x0008
+	DIRECTORY_ALL_ACCESS          = STANDARD_RIGHTS_REQUIRED | 0xF
+)
+
+//Object attribute flags (OBJ_*), for use with bananaphone.NewObjectAttributes
+const (
+	OBJ_INHERIT            = 0x00000002
+	OBJ_PERMANENT          = 0x00000010
+	OBJ_EXCLUSIVE          = 0x00000020
+	OBJ_CASE_INSENSITIVE   = 0x00000040
+	OBJ_OPENIF             = 0x00000080
+	OBJ_OPENLINK           = 0x00000100
+	OBJ_KERNEL_HANDLE      = 0x00000200
+	OBJ_FORCE_ACCESS_CHECK = 0x00000400
+	OBJ_DONT_REPARSE       = 0x00001000
+)
+
+//Common NTSTATUS values (STATUS_*). These are compared against the errcode returned by bananaphone.Syscall.
+const (
+	STATUS_SUCCESS                = 0x00000000
+	STATUS_WAIT_0                 = 0x00000000
+	STATUS_ABANDONED              = 0x00000080
+	STATUS_TIMEOUT                = 0x00000102
+	STATUS_PENDING                = 0x00000103
+	STATUS_MORE_ENTRIES           = 0x00000105
+	STATUS_BUFFER_OVERFLOW        = 0x80000005
+	STATUS_NO_MORE_FILES          = 0x80000006
+	STATUS_NO_MORE_ENTRIES        = 0x8000001A
+	STATUS_UNSUCCESSFUL           = 0xC0000001
+	STATUS_NOT_IMPLEMENTED        = 0xC0000002
+	STATUS_INVALID_INFO_CLASS     = 0xC0000003
+	STATUS_INFO_LENGTH_MISMATCH   = 0xC0000004
+	STATUS_ACCESS_VIOLATION       = 0xC0000005
+	STATUS_INVALID_HANDLE         = 0xC0000008
+	STATUS_INVALID_CID            = 0xC000000B
+	STATUS_INVALID_PARAMETER      = 0xC000000D
+	STATUS_NO_MEMORY              = 0xC0000017
+	STATUS_CONFLICTING_ADDRESSES  = 0xC0000018
+	STATUS_INVALID_SYSTEM_SERVICE = 0xC000001C
+	STATUS_ACCESS_DENIED          = 0xC0000022
+	STATUS_BUFFER_TOO_SMALL       = 0xC0000023
+	STATUS_OBJECT_TYPE_MISMATCH   = 0xC0000024
+	STATUS_OBJECT_NAME_INVALID    = 0xC0000033
+	STATUS_OBJECT_NAME_NOT_FOUND  = 0xC0000034
+	STATUS_OBJECT_NAME_COLLISION  = 0xC0000035
+	STATUS_OBJECT_PATH_NOT_FOUND  = 0xC000003A
+	STATUS_PARTIAL_COPY           = 0x8000000D
+	STATUS_PRIVILEGE_NOT_HELD     = 0xC0000061
+	STATUS_INSUFFICIENT_RESOURCES = 0xC000009A
+	STATUS_NOT_SUPPORTED          = 0xC00000BB
+	STATUS_PROCESS_IS_TERMINATING = 0xC000010A
+)
@@ -0,0 +1,137 @@
+package bananaphone
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/C-Sto/BananaPhone/pkg/BananaPhone/ntconst"
+)
+
+//ObjectDirectoryEntry is a single object in an object manager directory.
+type ObjectDirectoryEntry struct {
+	Name string
+	//TypeName is the object type, eg Section, SymbolicLink, Directory, ALPC Port.
+	TypeName string
+}
+
+//objectDirectoryInformation is OBJECT_DIRECTORY_INFORMATION, as returned by NtQueryDirectoryObject.
+type objectDirectoryInformation struct {
+	Name     UnicodeString
+	TypeName UnicodeString
+}
+
+//OpenDirectoryObject opens an object manager directory (eg \KnownDlls, \BaseNamedObjects) using NtOpenDirectoryObject, with the provided access rights (ntconst.DIRECTORY_*). Close the handle with CloseHandle.
+func (b *BananaPhone) OpenDirectoryObject(path string, access uint32) (uintptr, error) {
+	sysid, e := b.GetSysID("NtOpenDirectoryObject")
+	if e != nil {
+		return 0, e
+	}
+	oa, e := NewObjectAttributes(path, ntconst.OBJ_CASE_INSENSITIVE)
+	if e != nil {
+		return 0, e
+	}
+	var handle uintptr
+	var pin Pinner
+	defer pin.Unpin()
+	pin.Pin(&handle)
+	pin.Pin(oa)
+	r, e := b.Syscall(
+		sysid,
+		uintptr(unsafe.Pointer(&handle)),
+		uintptr(access),
+		uintptr(unsafe.Pointer(oa)),
+	)
+	if e != nil {
+		return 0, fmt.Errorf("NtOpenDirectoryObject failed: %w", NTStatus(r))
+	}
+	return handle, nil
+}
+
+//limits for QueryDirectoryObject's buffer. It only has to grow until a single entry fits, and an entry is two UNICODE_STRINGs of at most 64KiB each.
+const (
+	maxObjectDirectorySize    = 1 << 20
+	maxObjectDirectoryRetries = 8
+)
+
+//QueryDirectoryObject lists the objects in a directory opened (with DIRECTORY_QUERY) by OpenDirectoryObject, using NtQueryDirectoryObject.
+func (b *BananaPhone) QueryDirectoryObject(handle uintptr) ([]ObjectDirectoryEntry, error) {
+	sysid, e := b.GetSysID("NtQueryDirectoryObject")
+	if e != nil {
+		return nil, e
+	}
+	ret := []ObjectDirectoryEntry{}
+	buf := make([]byte, 0x1000)
+	var context, retlen uint32
+	restart := uintptr(1)
+	grows := 0
+	for {
+		var pin Pinner
+		pin.Pin(&buf[0])
+		pin.Pin(&context)
+		pin.Pin(&retlen)
+		r, _ := b.Syscall(
+			sysid,
+			handle,
+			uintptr(unsafe.Pointer(&buf[0])),
+			uintptr(len(buf)),
+			0, //ReturnSingleEntry
+			restart,
+			uintptr(unsafe.Pointer(&context)),
+			uintptr(unsafe.Pointer(&retlen)),
+		)
+		pin.Unpin()
+		switch r {
+		case ntconst.STATUS_NO_MORE_ENTRIES:
+			return ret, nil
+		case ntconst.STATUS_BUFFER_TOO_SMALL:
+			//not even one entry fit
+			grows++
+			if len(buf)*2 > maxObjectDirectorySize || grows >= maxObjectDirectoryRetries {
+				return nil, fmt.Errorf("NtQueryDirectoryObject failed: buffer still too small after %d tries (%d bytes): %w", grows, len(buf), NTStatus(r))
+			}
+			buf = make([]byte, len(buf)*2)
+			continue
+		}
+		if !NTStatus(r).IsSuccess() {
+			return nil, fmt.Errorf("NtQueryDirectoryObject failed: %w", NTStatus(r))
+		}
+		restart = 0
+		//the entries are an array terminated by an empty one, with the strings they point to after it in the same buffer
+		size := unsafe.Sizeof(objectDirectoryInformation{})
+		for off := uintptr(0); off+size <= uintptr(len(buf)); off += size {
+			info := (*objectDirectoryInformation)(unsafe.Pointer(&buf[off]))
+			if info.Name.Buffer == nil {
+				break
+			}
+			ret = append(ret, ObjectDirectoryEntry{Name: info.Name.String(), TypeName: info.TypeName.String()})
+		}
+		if r != ntconst.STATUS_MORE_ENTRIES {
+			return ret, nil
+		}
+	}
+}
+
+//ListObjectDirectory lists the objects in the object manager directory at path, eg \, \KnownDlls, \RPC Control or \Sessions\1\BaseNamedObjects.
+func (b *BananaPhone) ListObjectDirectory(path string) ([]ObjectDirectoryEntry, error) {
+	h, e := b.OpenDirectoryObject(path, ntconst.DIRECTORY_QUERY)
+	if e != nil {
+		return nil, e
+	}
+	defer b.CloseHandle(h)
+	return b.QueryDirectoryObject(h)
+}
+
+//ListKnownDlls lists the sections in \KnownDlls - the dlls that are mapped from the shared, pre-made sections rather than from disk.
+func (b *BananaPhone) ListKnownDlls() ([]ObjectDirectoryEntry, error) {
+	ents, e := b.ListObjectDirectory(`\KnownDlls`)
+	if e != nil {
+		return nil, e
+	}
+	ret := ents[:0]
+	for _, ent := range ents {
+		if ent.TypeName == "Section" {
+			ret = append(ret, ent)
+		}
+	}
+	return ret, nil
+}
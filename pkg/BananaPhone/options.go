@@ -0,0 +1,33 @@
+package bananaphone
+
+//Option configures a BananaPhone when it's created, see the With* functions.
+type Option func(*BananaPhone)
+
+//WithExecutor makes the phone's Syscall method run on the provided Executor's thread. Only calls made through the phone (or things built on it, eg ReadProcessMemory) are affected, the package level Syscall isn't.
+func WithExecutor(x *Executor) Option {
+	return func(b *BananaPhone) {
+		b.exec = x
+	}
+}
+
+//WithSysIDOverrides pins the sysids of the provided functions (ordinals can be given as "#123"). Overrides take precedence over every resolution mode and are never resolved, cached or thrown away - use this when you got the numbers out-of-band for a build where resolving them isn't reliable.
+func WithSysIDOverrides(overrides map[string]uint16) Option {
+	return func(b *BananaPhone) {
+		if b.overrides == nil {
+			b.overrides = make(map[string]uint16, len(overrides))
+		}
+		for k, v := range overrides {
+			b.overrides[k] = v
+		}
+	}
+}
+
+//WithSysIDFeed gives the phone a feed to fall back on when a sysid can't be resolved any other way the mode allows. The table is picked by the build number in the file version of the module the phone resolves against, the same number syscalltable's Table.Feed keys by. Unlike overrides, the feed never beats a clean resolution. Giving more than one feed merges them, later ones winning.
+func WithSysIDFeed(f SysIDFeed) Option {
+	return func(b *BananaPhone) {
+		if b.feed == nil {
+			b.feed = SysIDFeed{}
+		}
+		b.feed.Merge(f)
+	}
+}
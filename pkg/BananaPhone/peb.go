@@ -0,0 +1,99 @@
+package bananaphone
+
+//PEB is the start of the x64 Process Environment Block, up to the OS version fields. Everything past that changes between versions and isn't very interesting anyway. Get a copy with ReadPEB.
+type PEB struct {
+	InheritedAddressSpace    byte
+	ReadImageFileExecOptions byte
+	BeingDebugged            byte
+	BitField                 byte
+	_                        [4]byte
+	Mutant                   uintptr
+	ImageBaseAddress         uintptr
+	Ldr                      uintptr //*PEB_LDR_DATA
+	ProcessParameters        uintptr //*RTL_USER_PROCESS_PARAMETERS
+	SubSystemData            uintptr
+	ProcessHeap              uintptr
+	FastPebLock              uintptr
+	AtlThunkSListPtr         uintptr
+	IFEOKey                  uintptr
+	CrossProcessFlags        uint32
+	_                        uint32
+	KernelCallbackTable      uintptr
+	SystemReserved           uint32
+	AtlThunkSListPtr32       uint32
+	ApiSetMap                uintptr
+	TlsExpansionCounter      uint32
+	_                        uint32
+	TlsBitmap                uintptr
+	TlsBitmapBits            [2]uint32
+	ReadOnlySharedMemoryBase uintptr
+	SharedData               uintptr
+	ReadOnlyStaticServerData uintptr
+	AnsiCodePageData         uintptr
+	OemCodePageData          uintptr
+	UnicodeCaseTableData     uintptr
+	NumberOfProcessors       uint32
+	NtGlobalFlag             uint32
+	CriticalSectionTimeout   int64
+	HeapSegmentReserve       uintptr
+	HeapSegmentCommit        uintptr
+	HeapDeCommitTotalFree    uintptr
+	HeapDeCommitFreeBlock    uintptr
+	NumberOfHeaps            uint32
+	MaximumNumberOfHeaps     uint32
+	ProcessHeaps             uintptr
+	GdiSharedHandleTable     uintptr
+	ProcessStarterHelper     uintptr
+	GdiDCAttributeList       uint32
+	_                        uint32
+	LoaderLock               uintptr
+	OSMajorVersion           uint32
+	OSMinorVersion           uint32
+	OSBuildNumber            uint16
+	OSCSDVersion             uint16
+	OSPlatformID             uint32
+}
+
+//IsBeingDebugged returns the BeingDebugged flag from the PEB.
+func (p PEB) IsBeingDebugged() bool {
+	return p.BeingDebugged != 0
+}
+
+//OSVersion returns the major, minor and build number of Windows as recorded in the PEB. Unlike GetVersionEx, this isn't subject to manifest based lies.
+func (p PEB) OSVersion() (major, minor uint32, build uint16) {
+	return p.OSMajorVersion, p.OSMinorVersion, p.OSBuildNumber
+}
+
+//RtlUserProcessParameters is the start of RTL_USER_PROCESS_PARAMETERS, pointed to by PEB.ProcessParameters.
+type RtlUserProcessParameters struct {
+	MaximumLength          uint32
+	Length                 uint32
+	Flags                  uint32
+	DebugFlags             uint32
+	ConsoleHandle          uintptr
+	ConsoleFlags           uint32
+	_                      uint32
+	StandardInput          uintptr
+	StandardOutput         uintptr
+	StandardError          uintptr
+	CurrentDirectoryPath   UnicodeString //CURDIR.DosPath
+	CurrentDirectoryHandle uintptr       //CURDIR.Handle
+	DllPath                UnicodeString
+	ImagePathName          UnicodeString
+	CommandLine            UnicodeString
+	Environment            uintptr
+	StartingX              uint32
+	StartingY              uint32
+	CountX                 uint32
+	CountY                 uint32
+	CountCharsX            uint32
+	CountCharsY            uint32
+	FillAttribute          uint32
+	WindowFlags            uint32
+	ShowWindowFlags        uint32
+	_                      uint32
+	WindowTitle            UnicodeString
+	DesktopInfo            UnicodeString
+	ShellInfo              UnicodeString
+	RuntimeData            UnicodeString
+}
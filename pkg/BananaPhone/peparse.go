@@ -0,0 +1,136 @@
+package bananaphone
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/Binject/debug/pe"
+	"github.com/awgh/rawreader"
+)
+
+//exportDirectorySize is the size of IMAGE_EXPORT_DIRECTORY.
+const exportDirectorySize = 40
+
+//binject/debug trusts the headers it's handed and slices straight into them, so a module that's been tampered with (or half overwritten) can panic the whole process rather than returning an error. Everything that parses a module goes through the helpers below, which sanity check the bits that get indexed and turn anything that still blows up into an ErrMalformedImage.
+
+//recoverMalformed turns a panic from the pe package into an ErrMalformedImage. It must be deferred directly.
+func recoverMalformed(what string, err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("%w: %s: %v", ErrMalformedImage, what, r)
+	}
+}
+
+//parsePE parses the module mapped at base.
+func parsePE(base, size uintptr) (p *pe.File, err error) {
+	defer recoverMalformed(fmt.Sprintf("module at %#x", base), &err)
+	p, err = pe.NewFileFromMemory(rawreader.New(base, int(size)))
+	if err != nil {
+		return nil, err
+	}
+	if err = checkSections(p); err != nil {
+		return nil, err
+	}
+	if s := sizeOfImage(p); uintptr(s) > size {
+		return nil, fmt.Errorf("%w: SizeOfImage %#x is bigger than the %#x mapped", ErrMalformedImage, s, size)
+	}
+	return p, nil
+}
+
+//openPE parses the module at path on disk.
+func openPE(path string) (p *pe.File, err error) {
+	defer recoverMalformed(path, &err)
+	p, err = pe.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkSections(p); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+//peExports returns the export table of p.
+func peExports(p *pe.File) (ex []pe.Export, err error) {
+	if err = checkExportDirectory(p); err != nil {
+		return nil, err
+	}
+	defer recoverMalformed("export table", &err)
+	return p.Exports()
+}
+
+//sizeOfImage returns the SizeOfImage from the optional header.
+func sizeOfImage(p *pe.File) uint32 {
+	switch oh := p.OptionalHeader.(type) {
+	case *pe.OptionalHeader64:
+		return oh.SizeOfImage
+	case *pe.OptionalHeader32:
+		return oh.SizeOfImage
+	}
+	return 0
+}
+
+//exportDataDirectory returns the export data directory entry, or a zero entry if there isn't one.
+func exportDataDirectory(p *pe.File) pe.DataDirectory {
+	switch oh := p.OptionalHeader.(type) {
+	case *pe.OptionalHeader64:
+		if oh.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_EXPORT {
+			return oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_EXPORT]
+		}
+	case *pe.OptionalHeader32:
+		if oh.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_EXPORT {
+			return oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_EXPORT]
+		}
+	}
+	return pe.DataDirectory{}
+}
+
+//checkSections makes sure every section fits inside the image, so reading one can't go wild (or try to allocate 4GB).
+func checkSections(p *pe.File) error {
+	limit := uint64(sizeOfImage(p))
+	for _, s := range p.Sections {
+		if uint64(s.VirtualAddress)+uint64(s.VirtualSize) > limit || uint64(s.Size) > limit {
+			return fmt.Errorf("%w: section %q (rva %#x, size %#x/%#x) is outside the %#x byte image", ErrMalformedImage, s.Name, s.VirtualAddress, s.VirtualSize, s.Size, limit)
+		}
+	}
+	return nil
+}
+
+//checkExportDirectory makes sure the export directory and the tables it points at are inside the section that holds it, which is where pe.Exports expects to find them.
+func checkExportDirectory(p *pe.File) error {
+	edd := exportDataDirectory(p)
+	if edd.VirtualAddress == 0 {
+		return nil
+	}
+	ds := sectionForRVA(p, edd.VirtualAddress)
+	if ds == nil {
+		return nil //pe.Exports treats this as no exports
+	}
+	inSection := func(rva uint32, n uint64) bool {
+		return rva >= ds.VirtualAddress && uint64(rva-ds.VirtualAddress)+n <= uint64(ds.Size)
+	}
+	if !inSection(edd.VirtualAddress, exportDirectorySize) {
+		return fmt.Errorf("%w: export directory at rva %#x runs off the end of %s", ErrMalformedImage, edd.VirtualAddress, ds.Name)
+	}
+	dir, e := readRVA(p, edd.VirtualAddress, exportDirectorySize)
+	if e != nil {
+		return fmt.Errorf("%w: reading export directory: %v", ErrMalformedImage, e)
+	}
+	functions := uint64(binary.LittleEndian.Uint32(dir[20:]))
+	names := uint64(binary.LittleEndian.Uint32(dir[24:]))
+	tables := []struct {
+		name string
+		rva  uint32
+		size uint64
+	}{
+		{"address", binary.LittleEndian.Uint32(dir[28:]), functions * 4},
+		{"name", binary.LittleEndian.Uint32(dir[32:]), names * 4},
+		{"ordinal", binary.LittleEndian.Uint32(dir[36:]), names * 2},
+	}
+	for _, t := range tables {
+		if t.size > 0 && !inSection(t.rva, t.size) {
+			return fmt.Errorf("%w: export %s table (rva %#x, %#x bytes) runs off the end of %s", ErrMalformedImage, t.name, t.rva, t.size, ds.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+//go:build go1.21
+// +build go1.21
+
+package bananaphone
+
+import "runtime"
+
+//Pinner pins Go values in memory so pointers to them can be handed to a syscall as uintptrs, and unpins them once the syscall is done with them. From Go 1.21 it's a runtime.Pinner, which keeps checkptr happy and will keep working if the GC ever starts moving heap objects around. The zero value is ready to use. Unpin must be called once the pointers aren't needed.
+type Pinner struct {
+	p runtime.Pinner
+}
+
+//Pin pins the value pointer points to. It must be a pointer or unsafe.Pointer, nil is ignored.
+func (p *Pinner) Pin(pointer interface{}) {
+	if pointer == nil {
+		return
+	}
+	p.p.Pin(pointer)
+}
+
+//Unpin unpins everything pinned by p.
+func (p *Pinner) Unpin() {
+	p.p.Unpin()
+}
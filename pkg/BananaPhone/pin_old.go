@@ -0,0 +1,22 @@
+//go:build !go1.21
+// +build !go1.21
+
+package bananaphone
+
+//Pinner is the pre Go 1.21 Pinner (see pin.go). There's no runtime.Pinner, but the GC doesn't move heap objects either, so holding a reference is enough.
+type Pinner struct {
+	refs []interface{}
+}
+
+//Pin keeps the value pointer points to alive. nil is ignored.
+func (p *Pinner) Pin(pointer interface{}) {
+	if pointer == nil {
+		return
+	}
+	p.refs = append(p.refs, pointer)
+}
+
+//Unpin lets go of everything pinned by p.
+func (p *Pinner) Unpin() {
+	p.refs = nil
+}
@@ -0,0 +1,92 @@
+package bananaphone
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/C-Sto/BananaPhone/pkg/BananaPhone/ntconst"
+)
+
+//OpenProcess opens the process with the provided pid using NtOpenProcess, asking for the provided access rights (ntconst.PROCESS_*). Close the handle with CloseHandle.
+func (b *BananaPhone) OpenProcess(pid uint32, access uint32) (uintptr, error) {
+	sysid, e := b.GetSysID("NtOpenProcess")
+	if e != nil {
+		return 0, e
+	}
+	oa, _ := NewObjectAttributes("", 0)
+	cid := ClientID{UniqueProcess: uintptr(pid)}
+	var handle uintptr
+	var pin Pinner
+	defer pin.Unpin()
+	pin.Pin(&handle)
+	pin.Pin(oa)
+	pin.Pin(&cid)
+	r, e := b.Syscall(
+		sysid,
+		uintptr(unsafe.Pointer(&handle)),
+		uintptr(access),
+		uintptr(unsafe.Pointer(oa)),
+		uintptr(unsafe.Pointer(&cid)),
+	)
+	if e != nil {
+		return 0, fmt.Errorf("NtOpenProcess failed: %w", NTStatus(r))
+	}
+	return handle, nil
+}
+
+//CloseHandle closes a handle using NtClose.
+func (b *BananaPhone) CloseHandle(handle uintptr) error {
+	sysid, e := b.GetSysID("NtClose")
+	if e != nil {
+		return e
+	}
+	r, e := b.Syscall(sysid, handle)
+	if e != nil {
+		return fmt.Errorf("NtClose failed: %w", NTStatus(r))
+	}
+	return nil
+}
+
+//SuspendProcess suspends every thread in the process referred to by handle, using NtSuspendProcess. The handle needs PROCESS_SUSPEND_RESUME. Suspensions stack, each one needs a ResumeProcess.
+func (b *BananaPhone) SuspendProcess(handle uintptr) error {
+	sysid, e := b.GetSysID("NtSuspendProcess")
+	if e != nil {
+		return e
+	}
+	r, e := b.Syscall(sysid, handle)
+	if e != nil {
+		return fmt.Errorf("NtSuspendProcess failed: %w", NTStatus(r))
+	}
+	return nil
+}
+
+//ResumeProcess undoes a SuspendProcess, using NtResumeProcess. The handle needs PROCESS_SUSPEND_RESUME.
+func (b *BananaPhone) ResumeProcess(handle uintptr) error {
+	sysid, e := b.GetSysID("NtResumeProcess")
+	if e != nil {
+		return e
+	}
+	r, e := b.Syscall(sysid, handle)
+	if e != nil {
+		return fmt.Errorf("NtResumeProcess failed: %w", NTStatus(r))
+	}
+	return nil
+}
+
+//WithSuspended suspends the process with the provided pid, runs fn, and resumes it again - even if fn panics (the panic carries on once the process is resumed). The error from fn is returned, or the error from resuming if fn succeeded. Don't suspend your own process with this, nothing would be left to resume it.
+func (b *BananaPhone) WithSuspended(pid uint32, fn func() error) (err error) {
+	handle, e := b.OpenProcess(pid, ntconst.PROCESS_SUSPEND_RESUME)
+	if e != nil {
+		return e
+	}
+	defer b.CloseHandle(handle)
+	if e := b.SuspendProcess(handle); e != nil {
+		return e
+	}
+	defer func() {
+		if e := b.ResumeProcess(handle); e != nil && err == nil {
+			err = e
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,78 @@
+//go:build windows && amd64
+// +build windows,amd64
+
+package bananaphone
+
+import (
+	"fmt"
+	"strings"
+)
+
+//processParameters returns a pointer to the live process parameters of the current process.
+func processParameters() *RtlUserProcessParameters {
+	return (*RtlUserProcessParameters)(uintptrToPointer(ReadPEB().ProcessParameters))
+}
+
+//CommandLine returns the command line of the current process, read straight out of the PEB with no API calls.
+func CommandLine() string {
+	return processParameters().CommandLine.String()
+}
+
+//ImagePath returns the path of the current process' image, read straight out of the PEB with no API calls.
+func ImagePath() string {
+	return processParameters().ImagePathName.String()
+}
+
+//CurrentDirectory returns the current directory of the process, read straight out of the PEB with no API calls.
+func CurrentDirectory() string {
+	return processParameters().CurrentDirectoryPath.String()
+}
+
+//WindowTitle returns the window title the process was started with (often the image path for console apps), read straight out of the PEB with no API calls.
+func WindowTitle() string {
+	return processParameters().WindowTitle.String()
+}
+
+//EnvironmentStrings returns the raw "NAME=value" strings from the environment block of the current process, read from the PEB without calling GetEnvironmentStrings.
+func EnvironmentStrings() []string {
+	ret := []string{}
+	ptr := processParameters().Environment
+	if ptr == 0 {
+		return ret
+	}
+	//the block is a sequence of null terminated UTF-16 strings, ending with an empty one
+	for {
+		s, n := utf16PtrToStringLen(ptr)
+		if n == 0 {
+			break
+		}
+		ret = append(ret, s)
+		ptr += uintptr(n+1) * 2
+	}
+	return ret
+}
+
+//Environment parses the environment block of the current process into a map, read from the PEB without calling GetEnvironmentStrings. The hidden per-drive "=C:" style entries are included as-is.
+func Environment() (map[string]string, error) {
+	ret := make(map[string]string)
+	for _, kv := range EnvironmentStrings() {
+		//skip the first char so the "=C:=C:\" entries split correctly
+		i := strings.Index(kv[1:], "=")
+		if i < 0 {
+			return ret, fmt.Errorf("malformed environment entry: %s", kv)
+		}
+		ret[kv[:i+1]] = kv[i+2:]
+	}
+	return ret, nil
+}
+
+//Lookup returns the value of the named environment variable from the PEB environment block. Names are case insensitive, just like on Windows.
+func Lookup(name string) (string, bool) {
+	env, _ := Environment()
+	for k, v := range env {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
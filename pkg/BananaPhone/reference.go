@@ -0,0 +1,85 @@
+package bananaphone
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+//ErrNoReferenceTable is returned by Verify when there is no known-good table for the running build.
+var ErrNoReferenceTable = errors.New("no reference sysid table for this build")
+
+//referenceTable is a set of known-good x64 sysids for a range of builds (inclusive, maxBuild 0 means no upper bound).
+type referenceTable struct {
+	minBuild, maxBuild uint32
+	sysids             map[string]uint16
+}
+
+//referenceTables are the canaries checked by Verify. They're low numbered, commonly used calls that haven't moved within each range, so anything else coming back is a resolution bug (eg a Halo's Gate off-by-one), not a new build.
+var referenceTables = []referenceTable{
+	{minBuild: 7600, maxBuild: 7601, sysids: map[string]uint16{ //Windows 7 / 2008 R2
+		"NtClose": 0x0c, "NtQueryInformationProcess": 0x16, "NtAllocateVirtualMemory": 0x15, "NtFreeVirtualMemory": 0x1b,
+		"NtQueryVirtualMemory": 0x20, "NtOpenProcess": 0x23, "NtWriteVirtualMemory": 0x37, "NtReadVirtualMemory": 0x3c, "NtProtectVirtualMemory": 0x4d,
+	}},
+	{minBuild: 9200, maxBuild: 9200, sysids: map[string]uint16{ //Windows 8 / 2012
+		"NtClose": 0x0d, "NtQueryInformationProcess": 0x17, "NtAllocateVirtualMemory": 0x16, "NtFreeVirtualMemory": 0x1c,
+		"NtQueryVirtualMemory": 0x21, "NtOpenProcess": 0x24, "NtWriteVirtualMemory": 0x38, "NtReadVirtualMemory": 0x3d, "NtProtectVirtualMemory": 0x4e,
+	}},
+	{minBuild: 9600, maxBuild: 9600, sysids: map[string]uint16{ //Windows 8.1 / 2012 R2
+		"NtClose": 0x0e, "NtQueryInformationProcess": 0x18, "NtAllocateVirtualMemory": 0x17, "NtFreeVirtualMemory": 0x1d,
+		"NtQueryVirtualMemory": 0x22, "NtOpenProcess": 0x25, "NtWriteVirtualMemory": 0x39, "NtReadVirtualMemory": 0x3e, "NtProtectVirtualMemory": 0x4f,
+	}},
+	{minBuild: 10240, sysids: map[string]uint16{ //Windows 10 and 11, these haven't changed since 1507
+		"NtClose": 0x0f, "NtQueryInformationProcess": 0x19, "NtAllocateVirtualMemory": 0x18, "NtFreeVirtualMemory": 0x1e,
+		"NtQueryVirtualMemory": 0x23, "NtOpenProcess": 0x26, "NtWriteVirtualMemory": 0x3a, "NtReadVirtualMemory": 0x3f, "NtProtectVirtualMemory": 0x50,
+	}},
+}
+
+//referenceFor returns the canary table for a build, or nil if there isn't one.
+func referenceFor(build uint32) map[string]uint16 {
+	for _, t := range referenceTables {
+		if build >= t.minBuild && (t.maxBuild == 0 || build <= t.maxBuild) {
+			return t.sysids
+		}
+	}
+	return nil
+}
+
+//Discrepancy is a canary that didn't resolve to the known-good sysid.
+type Discrepancy struct {
+	Func string
+	Want uint16
+	Got  uint16
+	//Err is set if the canary couldn't be resolved at all.
+	Err error
+}
+
+func (d Discrepancy) String() string {
+	if d.Err != nil {
+		return fmt.Sprintf("%s: wanted %#x, got error: %v", d.Func, d.Want, d.Err)
+	}
+	return fmt.Sprintf("%s: wanted %#x, got %#x", d.Func, d.Want, d.Got)
+}
+
+//Verify resolves a handful of canary syscalls with the phone and checks them against known-good numbers for the running build. Any that don't match are returned - if the list isn't empty, don't trust anything else the phone resolves either. ErrNoReferenceTable is returned if the build isn't one we have numbers for (only x64 builds are known).
+func (b *BananaPhone) Verify() ([]Discrepancy, error) {
+	_, _, build := GetWindowsBuild()
+	ref := referenceFor(build)
+	if ref == nil {
+		return nil, fmt.Errorf("%w: %d", ErrNoReferenceTable, build)
+	}
+	names := make([]string, 0, len(ref))
+	for n := range ref {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	ret := []Discrepancy{}
+	for _, n := range names {
+		got, e := b.GetSysID(n)
+		if e != nil || got != ref[n] {
+			ret = append(ret, Discrepancy{Func: n, Want: ref[n], Got: got, Err: e})
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,80 @@
+package bananaphone
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//Report is the result of BananaPhone.Report.
+type Report struct {
+	//Checked is how many Nt*/Zw* exports were tried, Resolved how many of them gave a sysid.
+	Checked, Resolved int
+	//Failures are the exports that didn't resolve, sorted by name.
+	Failures []ReportFailure
+}
+
+//ReportFailure is an export that couldn't be resolved, and why.
+type ReportFailure struct {
+	Name string
+	//Reason is a short category: "hooked" (the stub didn't look like a syscall stub and the mode had no fallback that worked), "not a syscall", "malformed image", "not found" or "error" for anything else.
+	Reason string
+	Err    error
+}
+
+//String summarises the report, one failure per line.
+func (r Report) String() string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "%d of %d Nt*/Zw* exports resolved\n", r.Resolved, r.Checked)
+	for _, f := range r.Failures {
+		fmt.Fprintf(sb, "%-50s %-16s %v\n", f.Name, f.Reason, f.Err)
+	}
+	return sb.String()
+}
+
+//Report tries to resolve every Nt*/Zw* export of the phone's module with the phone's normal fallbacks and lists the ones that failed, as a health check of the environment before doing anything that matters. Exports that aren't syscalls (eg NtdllDefWindowProc_A) are expected to show up as "not a syscall". Sysids that do resolve are cached like GetSysID.
+func (b *BananaPhone) Report() (Report, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	exports, e := b.getExports()
+	if e != nil {
+		return Report{}, e
+	}
+	names := []string{}
+	for _, ex := range exports {
+		if strings.HasPrefix(ex.Name, "Nt") || strings.HasPrefix(ex.Name, "Zw") {
+			names = append(names, ex.Name)
+		}
+	}
+	sort.Strings(names)
+
+	r := Report{Checked: len(names), Failures: []ReportFailure{}}
+	for _, n := range names {
+		if _, ok := b.overrides[n]; ok {
+			r.Resolved++
+			continue
+		}
+		if _, e := b.getSysIDDetailed(n); e != nil {
+			r.Failures = append(r.Failures, ReportFailure{Name: n, Reason: failureReason(e), Err: e})
+			continue
+		}
+		r.Resolved++
+	}
+	return r, nil
+}
+
+//failureReason sorts a resolution error into one of the ReportFailure reasons.
+func failureReason(e error) string {
+	switch {
+	case errors.Is(e, ErrUnsupportedStub):
+		return "hooked"
+	case errors.Is(e, ErrNotASyscall):
+		return "not a syscall"
+	case errors.Is(e, ErrMalformedImage):
+		return "malformed image"
+	case errors.Is(e, ErrFunctionNotFound):
+		return "not found"
+	}
+	return "error"
+}
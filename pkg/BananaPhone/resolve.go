@@ -0,0 +1,122 @@
+//go:build windows && amd64
+// +build windows,amd64
+
+package bananaphone
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Binject/debug/pe"
+)
+
+//maxForwardDepth is how many forwarded exports we will follow before deciding something is very wrong.
+const maxForwardDepth = 8
+
+//ResolveExport is a GetProcAddress equivalent for any module loaded in the current process. The module is located by walking the PEB (full path, base name, or base name without the .dll extension all work), the export table is parsed from memory, and forwarded exports (eg kernel32!HeapAlloc -> ntdll!RtlAllocateHeap) are followed to the final address, with api set forwarders (api-ms-win-*) resolved through the PEB's ApiSetMap. Exports can be specified by ordinal with the "#123" form.
+func ResolveExport(module, name string) (uintptr, error) {
+	return resolveExport(module, name, 0)
+}
+
+func resolveExport(module, name string, depth int) (uintptr, error) {
+	if depth > maxForwardDepth {
+		return 0, fmt.Errorf("too many forwarded exports resolving %s!%s", module, name)
+	}
+	base, size, e := GetModuleBase(module)
+	if e != nil {
+		return 0, e
+	}
+	p, e := parsePE(base, size)
+	if e != nil {
+		return 0, e
+	}
+	exports, e := peExports(p)
+	if e != nil {
+		return 0, e
+	}
+
+	var ord uint32
+	useOrd := strings.HasPrefix(name, "#")
+	if useOrd {
+		o, e := strconv.ParseUint(name[1:], 10, 32)
+		if e != nil {
+			return 0, fmt.Errorf("bad ordinal %s: %w", name, e)
+		}
+		ord = uint32(o)
+	}
+
+	for _, ex := range exports {
+		if (useOrd && ex.Ordinal == ord) || (!useOrd && ex.Name == name) {
+			if !isForwarder(p, ex.VirtualAddress) {
+				return base + uintptr(ex.VirtualAddress), nil
+			}
+			//forwarders are stored as "MODULE.Function" or "MODULE.#ordinal" strings inside the export directory
+			fwd := cString(base + uintptr(ex.VirtualAddress))
+			dot := strings.LastIndex(fwd, ".")
+			if dot < 0 {
+				return 0, fmt.Errorf("malformed forwarder for %s!%s: %s", module, name, fwd)
+			}
+			fwdModule := fwd[:dot]
+			if isAPISet(fwdModule) {
+				host, e := resolveAPISet(fwdModule, module)
+				if e != nil {
+					return 0, fmt.Errorf("forwarder for %s!%s: %w", module, name, e)
+				}
+				fwdModule = host
+			}
+			if filepath.Ext(fwdModule) == "" {
+				fwdModule += ".dll"
+			}
+			return resolveExport(fwdModule, fwd[dot+1:], depth+1)
+		}
+	}
+	return 0, fmt.Errorf("%w: %s!%s", ErrFunctionNotFound, module, name)
+}
+
+//resolveAPISet maps an api set to the dll hosting it for importer (the module the forwarder was found in) using the PEB's ApiSetMap, the way the loader does.
+func resolveAPISet(name, importer string) (string, error) {
+	base := ReadPEB().ApiSetMap
+	if base == 0 {
+		return "", fmt.Errorf("%w: %s (no ApiSetMap)", ErrAPISetNotFound, name)
+	}
+	if v := *(*uint32)(uintptrToPointer(base)); v != apiSetSchemaVersion {
+		return "", fmt.Errorf("%w: %s (ApiSetMap schema version %d isn't supported)", ErrAPISetNotFound, name, v)
+	}
+	size := *(*uint32)(uintptrToPointer(base + 4))
+	return apiSetHost(memorySlice(base, uintptr(size)), name, filepath.Base(importer))
+}
+
+//isForwarder returns true if the export rva points inside the export directory, which is how the loader knows that it's a forwarder string and not code.
+func isForwarder(p *pe.File, rva uint32) bool {
+	var dd pe.DataDirectory
+	switch oh := p.OptionalHeader.(type) {
+	case *pe.OptionalHeader64:
+		if oh.NumberOfRvaAndSizes <= pe.IMAGE_DIRECTORY_ENTRY_EXPORT {
+			return false
+		}
+		dd = oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_EXPORT]
+	case *pe.OptionalHeader32:
+		if oh.NumberOfRvaAndSizes <= pe.IMAGE_DIRECTORY_ENTRY_EXPORT {
+			return false
+		}
+		dd = oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_EXPORT]
+	default:
+		return false
+	}
+	return rva >= dd.VirtualAddress && rva < dd.VirtualAddress+dd.Size
+}
+
+//cString reads a null terminated ascii string from the provided address.
+func cString(ptr uintptr) string {
+	var sb strings.Builder
+	for i := uintptr(0); ; i++ {
+		c := *(*byte)(uintptrToPointer(ptr + i))
+		if c == 0 {
+			break
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
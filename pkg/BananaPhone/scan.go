@@ -0,0 +1,77 @@
+package bananaphone
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//ParsePattern converts an IDA style signature ("48 8B ?? ?? 05") into a pattern and mask suitable for FindPattern. Wildcards can be written as ? or ??.
+func ParsePattern(sig string) (pattern []byte, mask string, err error) {
+	var sb strings.Builder
+	for _, tok := range strings.Fields(sig) {
+		if tok == "?" || tok == "??" {
+			pattern = append(pattern, 0)
+			sb.WriteByte('?')
+			continue
+		}
+		v, e := strconv.ParseUint(tok, 16, 8)
+		if e != nil {
+			return nil, "", fmt.Errorf("bad pattern byte %q: %v", tok, e)
+		}
+		pattern = append(pattern, byte(v))
+		sb.WriteByte('x')
+	}
+	if len(pattern) == 0 {
+		return nil, "", fmt.Errorf("empty pattern")
+	}
+	return pattern, sb.String(), nil
+}
+
+//FindPatternInBytes returns the offset of every match of pattern in data. The mask has one character per pattern byte: 'x' must match, '?' matches anything. An empty mask means every byte must match.
+func FindPatternInBytes(data, pattern []byte, mask string) ([]int, error) {
+	if mask == "" {
+		mask = strings.Repeat("x", len(pattern))
+	}
+	if len(mask) != len(pattern) {
+		return nil, fmt.Errorf("mask length %d does not match pattern length %d", len(mask), len(pattern))
+	}
+	ret := []int{}
+	for i := 0; i+len(pattern) <= len(data); i++ {
+		if matchAt(data, i, pattern, mask) {
+			ret = append(ret, i)
+		}
+	}
+	return ret, nil
+}
+
+//matchAt checks if pattern matches data at offset i.
+func matchAt(data []byte, i int, pattern []byte, mask string) bool {
+	for j := range pattern {
+		if mask[j] == 'x' && data[i+j] != pattern[j] {
+			return false
+		}
+	}
+	return true
+}
+
+//GadgetOptions controls which modules FindGadgets searches.
+type GadgetOptions struct {
+	//Mask for the pattern, see FindPatternInBytes. Empty means exact match.
+	Mask string
+	//SystemOnly restricts the search to modules loaded from the Windows directory.
+	SystemOnly bool
+	//ExcludeOwnImage skips the main executable of the process.
+	ExcludeOwnImage bool
+	//Modules restricts the search to the named modules (see GetModuleBase for name matching). Empty means all modules.
+	Modules []string
+	//Max stops the search after this many gadgets have been found. 0 means no limit.
+	Max int
+}
+
+//Gadget is a match found by FindGadgets.
+type Gadget struct {
+	Address uintptr
+	Module  string
+	Section string
+}
@@ -0,0 +1,69 @@
+package bananaphone
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestParsePattern(t *testing.T) {
+	tests := []struct {
+		sig     string
+		pattern []byte
+		mask    string
+		wantErr bool
+	}{
+		{"48 8B 05", []byte{0x48, 0x8b, 0x05}, "xxx", false},
+		{"48 ?? ? 05", []byte{0x48, 0, 0, 0x05}, "x??x", false},
+		{"  4c\t8b d1  ", []byte{0x4c, 0x8b, 0xd1}, "xxx", false},
+		{"??", []byte{0}, "?", false},
+		{"", nil, "", true},
+		{"   ", nil, "", true},
+		{"48 ??? 05", nil, "", true},
+		{"48 100", nil, "", true},
+		{"48 zz", nil, "", true},
+	}
+	for _, tt := range tests {
+		pattern, mask, e := ParsePattern(tt.sig)
+		if (e != nil) != tt.wantErr {
+			t.Errorf("%q: got err %v", tt.sig, e)
+			continue
+		}
+		if !bytes.Equal(pattern, tt.pattern) || mask != tt.mask {
+			t.Errorf("%q: got % x %q, want % x %q", tt.sig, pattern, mask, tt.pattern, tt.mask)
+		}
+	}
+}
+
+func TestFindPatternInBytes(t *testing.T) {
+	data := []byte{0x4c, 0x8b, 0xd1, 0xb8, 0x26, 0x00, 0x0f, 0x05, 0xaa, 0xaa, 0xaa, 0x0f, 0x05}
+	tests := []struct {
+		name    string
+		pattern []byte
+		mask    string
+		want    []int
+		wantErr bool
+	}{
+		{"exact", []byte{0xd1, 0xb8}, "xx", []int{2}, false},
+		{"empty mask is exact", []byte{0xd1, 0xb8}, "", []int{2}, false},
+		{"empty mask doesn't wildcard", []byte{0xd1, 0x00}, "", []int{}, false},
+		{"wildcard", []byte{0xb8, 0x00, 0x00}, "x??", []int{3}, false},
+		{"overlapping", []byte{0xaa, 0xaa}, "xx", []int{8, 9}, false},
+		{"at the end", []byte{0x0f, 0x05}, "xx", []int{6, 11}, false},
+		{"all wildcards", []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, "?????????????", []int{0}, false},
+		{"longer than data", make([]byte, 14), "", []int{}, false},
+		{"no match", []byte{0xc3}, "x", []int{}, false},
+		{"mask too short", []byte{0xd1, 0xb8}, "x", nil, true},
+		{"mask too long", []byte{0xd1, 0xb8}, "xxx", nil, true},
+	}
+	for _, tt := range tests {
+		got, e := FindPatternInBytes(data, tt.pattern, tt.mask)
+		if (e != nil) != tt.wantErr {
+			t.Errorf("%s: got err %v", tt.name, e)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+//go:build windows && amd64
+// +build windows,amd64
+
+package bananaphone
+
+import (
+	"strings"
+
+	"github.com/Binject/debug/pe"
+	"github.com/C-Sto/BananaPhone/pkg/BananaPhone/kuser"
+)
+
+//FindPattern searches the mapped range of a loaded module (see GetModuleBase for name matching) for pattern, returning the address of every match. See FindPatternInBytes for the mask format.
+func FindPattern(moduleName string, pattern []byte, mask string) ([]uintptr, error) {
+	base, size, e := GetModuleBase(moduleName)
+	if e != nil {
+		return nil, e
+	}
+	offsets, e := FindPatternInBytes(memorySlice(base, size), pattern, mask)
+	if e != nil {
+		return nil, e
+	}
+	ret := make([]uintptr, len(offsets))
+	for i, o := range offsets {
+		ret[i] = base + uintptr(o)
+	}
+	return ret, nil
+}
+
+//FindSignature is FindPattern with an IDA style signature string (see ParsePattern).
+func FindSignature(moduleName, sig string) ([]uintptr, error) {
+	pattern, mask, e := ParsePattern(sig)
+	if e != nil {
+		return nil, e
+	}
+	return FindPattern(moduleName, pattern, mask)
+}
+
+//FindGadgets searches the executable sections of every loaded module (found by walking the PEB and parsing section headers from memory) for pattern, filtered according to opts.
+func FindGadgets(pattern []byte, opts GadgetOptions) ([]Gadget, error) {
+	mods, e := InMemLoadsOrdered()
+	if e != nil {
+		return nil, e
+	}
+	sysroot := strings.ToLower(kuser.NtSystemRoot()) + `\`
+	ret := []Gadget{}
+	for i, m := range mods {
+		if opts.ExcludeOwnImage && i == 0 {
+			continue
+		}
+		if opts.SystemOnly && !strings.HasPrefix(strings.ToLower(m.FullPath), sysroot) {
+			continue
+		}
+		if len(opts.Modules) > 0 {
+			wanted := false
+			for _, n := range opts.Modules {
+				if matchModuleName(m.FullPath, n) {
+					wanted = true
+					break
+				}
+			}
+			if !wanted {
+				continue
+			}
+		}
+
+		p, e := parsePE(m.BaseAddr, m.Size)
+		if e != nil {
+			continue //not every module is going to parse nicely, skip it
+		}
+		for _, s := range p.Sections {
+			if s.Characteristics&pe.IMAGE_SCN_MEM_EXECUTE == 0 {
+				continue
+			}
+			if uintptr(s.VirtualAddress)+uintptr(s.VirtualSize) > m.Size {
+				continue
+			}
+			start := m.BaseAddr + uintptr(s.VirtualAddress)
+			offsets, e := FindPatternInBytes(memorySlice(start, uintptr(s.VirtualSize)), pattern, opts.Mask)
+			if e != nil {
+				return nil, e
+			}
+			for _, o := range offsets {
+				ret = append(ret, Gadget{Address: start + uintptr(o), Module: m.FullPath, Section: s.Name})
+				if opts.Max > 0 && len(ret) >= opts.Max {
+					return ret, nil
+				}
+			}
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,182 @@
+//go:build !windows || !amd64
+// +build !windows !amd64
+
+package bananaphone
+
+import (
+	"time"
+
+	"github.com/C-Sto/BananaPhone/pkg/BananaPhone/ntconst"
+)
+
+//This file provides the windows/amd64 only API on every other platform, so dependents still build. Anything that can return an error returns ErrUnsupportedPlatform, everything else is a no-op returning zero values.
+
+//Syscall returns STATUS_NOT_SUPPORTED and ErrUnsupportedPlatform on this platform.
+func Syscall(callid uint16, argh ...uintptr) (errcode uint32, err error) {
+	return ntconst.STATUS_NOT_SUPPORTED, ErrUnsupportedPlatform
+}
+
+//SyscallRecycledGate returns STATUS_NOT_SUPPORTED and ErrUnsupportedPlatform on this platform.
+func SyscallRecycledGate(callid uint16, argh ...uintptr) (errcode uint32, err error) {
+	return ntconst.STATUS_NOT_SUPPORTED, ErrUnsupportedPlatform
+}
+
+//CallFunction returns ErrUnsupportedPlatform on this platform.
+func CallFunction(addr uintptr, argh ...uintptr) (uintptr, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+//GetPEB returns 0 on this platform.
+func GetPEB() uintptr {
+	return 0
+}
+
+//GetTEB returns 0 on this platform.
+func GetTEB() uintptr {
+	return 0
+}
+
+//GetNtdllStart returns 0, 0 on this platform.
+func GetNtdllStart() (start uintptr, size uintptr) {
+	return 0, 0
+}
+
+//GetModuleLoadedOrderPtr returns nil on this platform.
+func GetModuleLoadedOrderPtr(i int) *LdrDataTableEntry {
+	return nil
+}
+
+//GetModuleCount returns 0 on this platform.
+func GetModuleCount() int {
+	return 0
+}
+
+//GetModuleLoadedOrder returns ErrUnsupportedPlatform on this platform.
+func GetModuleLoadedOrder(i int) (start uintptr, size uintptr, modulepath string, err error) {
+	return 0, 0, "", ErrUnsupportedPlatform
+}
+
+//InMemLoads returns ErrUnsupportedPlatform on this platform.
+func InMemLoads() (map[string]Image, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+//GetSysIDFromMemory returns ErrUnsupportedPlatform on this platform.
+func GetSysIDFromMemory(funcname string) (uint16, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+//GetSysIDFromDiskOrd returns ErrUnsupportedPlatform on this platform.
+func GetSysIDFromDiskOrd(ordinal uint32) (uint16, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+//GetSysIDFromDisk returns ErrUnsupportedPlatform on this platform.
+func GetSysIDFromDisk(funcname string) (uint16, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+//ReadPEB returns an empty PEB on this platform.
+func ReadPEB() PEB {
+	return PEB{}
+}
+
+//GetWindowsBuild returns 0, 0, 0 on this platform.
+func GetWindowsBuild() (major, minor, build uint32) {
+	return 0, 0, 0
+}
+
+//ReadTEB returns an empty TEB on this platform.
+func ReadTEB() TEB {
+	return TEB{}
+}
+
+//TEBTlsSlots returns empty slots on this platform.
+func TEBTlsSlots() [tebTlsSlotsCount]uintptr {
+	return [tebTlsSlotsCount]uintptr{}
+}
+
+//GetLastError returns 0 on this platform.
+func GetLastError() uint32 {
+	return 0
+}
+
+//GetLastNtStatus returns 0 on this platform.
+func GetLastNtStatus() NTStatus {
+	return 0
+}
+
+//GetModuleBase returns ErrUnsupportedPlatform on this platform.
+func GetModuleBase(name string) (base uintptr, size uintptr, err error) {
+	return 0, 0, ErrUnsupportedPlatform
+}
+
+//GetModuleBaseByHash returns ErrUnsupportedPlatform on this platform.
+func GetModuleBaseByHash(h uint32) (base uintptr, size uintptr, err error) {
+	return 0, 0, ErrUnsupportedPlatform
+}
+
+//InMemLoadsOrdered returns ErrUnsupportedPlatform on this platform.
+func InMemLoadsOrdered() ([]ModuleInfo, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+//WaitForModule returns ErrUnsupportedPlatform on this platform, without waiting.
+func WaitForModule(name string, timeout time.Duration) (base uintptr, size uintptr, err error) {
+	return 0, 0, ErrUnsupportedPlatform
+}
+
+//CommandLine returns an empty string on this platform.
+func CommandLine() string {
+	return ""
+}
+
+//ImagePath returns an empty string on this platform.
+func ImagePath() string {
+	return ""
+}
+
+//CurrentDirectory returns an empty string on this platform.
+func CurrentDirectory() string {
+	return ""
+}
+
+//WindowTitle returns an empty string on this platform.
+func WindowTitle() string {
+	return ""
+}
+
+//EnvironmentStrings returns nil on this platform.
+func EnvironmentStrings() []string {
+	return nil
+}
+
+//Environment returns ErrUnsupportedPlatform on this platform.
+func Environment() (map[string]string, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+//Lookup never finds anything on this platform.
+func Lookup(name string) (string, bool) {
+	return "", false
+}
+
+//ResolveExport returns ErrUnsupportedPlatform on this platform.
+func ResolveExport(module, name string) (uintptr, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+//FindPattern returns ErrUnsupportedPlatform on this platform.
+func FindPattern(moduleName string, pattern []byte, mask string) ([]uintptr, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+//FindSignature returns ErrUnsupportedPlatform on this platform.
+func FindSignature(moduleName, sig string) ([]uintptr, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+//FindGadgets returns ErrUnsupportedPlatform on this platform.
+func FindGadgets(pattern []byte, opts GadgetOptions) ([]Gadget, error) {
+	return nil, ErrUnsupportedPlatform
+}
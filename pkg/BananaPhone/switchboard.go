@@ -0,0 +1,121 @@
+package bananaphone
+
+import (
+	"fmt"
+	"sync"
+)
+
+//BananaSwitchboard routes lookups across several phones, eg one for ntdll and one for win32u, so code that mixes core and GUI syscalls can resolve everything by name without caring which module it lives in. The first phone (in the order they were added) that exports a function owns it, and that's remembered.
+type BananaSwitchboard struct {
+	mu     sync.Mutex
+	phones []*BananaPhone
+	owners map[string]*BananaPhone
+}
+
+var _ SyscallResolver = (*BananaSwitchboard)(nil)
+
+//NewBananaSwitchboard returns a switchboard routing to the provided phones. The switchboard owns them from here on, see Close.
+func NewBananaSwitchboard(phones ...*BananaPhone) *BananaSwitchboard {
+	s := &BananaSwitchboard{owners: make(map[string]*BananaPhone)}
+	for _, b := range phones {
+		s.Add(b)
+	}
+	return s
+}
+
+//Add adds a phone to the end of the switchboard. It's only asked about functions none of the existing phones export.
+func (s *BananaSwitchboard) Add(b *BananaPhone) {
+	if b == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.phones = append(s.phones, b)
+}
+
+//Phone returns the phone that owns funcname.
+func (s *BananaSwitchboard) Phone(funcname string) (*BananaPhone, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.owners[funcname]; ok {
+		return b, nil
+	}
+	//misses aren't remembered, the module might turn up later (see Add and BananaPhone.Refresh)
+	for _, b := range s.phones {
+		if b.hasExport(funcname) {
+			s.owners[funcname] = b
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s isn't exported by any module on the switchboard", ErrFunctionNotFound, funcname)
+}
+
+//GetSysID resolves funcname with the phone that owns it.
+func (s *BananaSwitchboard) GetSysID(funcname string) (uint16, error) {
+	b, e := s.Phone(funcname)
+	if e != nil {
+		return 0, e
+	}
+	return b.GetSysID(funcname)
+}
+
+//GetFuncPtr returns the address of funcname in the module that owns it.
+func (s *BananaSwitchboard) GetFuncPtr(funcname string) (uint64, error) {
+	b, e := s.Phone(funcname)
+	if e != nil {
+		return 0, e
+	}
+	return b.GetFuncPtr(funcname)
+}
+
+//NewProc returns a BananaProcedure for funcname in the module that owns it. As with BananaPhone.NewProc, any error is kept for Call (or Err) to return.
+func (s *BananaSwitchboard) NewProc(funcname string) BananaProcedure {
+	b, e := s.Phone(funcname)
+	if e != nil {
+		return BananaProcedure{err: e}
+	}
+	return b.NewProc(funcname)
+}
+
+//Syscall makes the syscall with the first phone on the switchboard, so its Executor is used if it has one. Sysids for every module go through the same syscall instruction, so it doesn't matter which phone resolved it.
+func (s *BananaSwitchboard) Syscall(sysid uint16, argh ...uintptr) (errcode uint32, err error) {
+	s.mu.Lock()
+	var b *BananaPhone
+	if len(s.phones) > 0 {
+		b = s.phones[0]
+	}
+	s.mu.Unlock()
+	if b == nil {
+		return Syscall(sysid, argh...)
+	}
+	return b.Syscall(sysid, argh...)
+}
+
+//Close closes every phone on the switchboard, returning the first error.
+func (s *BananaSwitchboard) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ret error
+	for _, b := range s.phones {
+		if e := b.Close(); e != nil && ret == nil {
+			ret = e
+		}
+	}
+	s.phones = nil
+	s.owners = make(map[string]*BananaPhone)
+	return ret
+}
+
+//hasExport reports whether the phone can answer for funcname at all: it's overridden, or the module exports it.
+func (b *BananaPhone) hasExport(funcname string) bool {
+	if _, ok := b.overrides[funcname]; ok {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, e := b.getExports(); e != nil {
+		return false
+	}
+	_, ok := b.byName[funcname]
+	return ok
+}
@@ -0,0 +1,273 @@
+package bananaphone
+
+import (
+	"fmt"
+	"time"
+	"unsafe"
+
+	"github.com/C-Sto/BananaPhone/pkg/BananaPhone/ntconst"
+)
+
+//EVENT_TYPE values for NtCreateEvent.
+const (
+	notificationEvent    = 0 //manual reset
+	synchronizationEvent = 1 //auto reset
+)
+
+//waitSlice is the longest single NtWaitForSingleObject call WaitForSingleObject makes. The goroutine isn't in syscall state while the kernel has it, so a stop-the-world GC has to wait for the call to come back - longer waits are made as a series of these.
+const waitSlice = 20 * time.Millisecond
+
+//WaitForSingleObject waits for the object referred to by handle to be signalled using NtWaitForSingleObject. A negative timeout waits forever. The raw status is returned: STATUS_WAIT_0 when signalled, STATUS_TIMEOUT, STATUS_ABANDONED for a mutant whose owner exited, or STATUS_USER_APC/STATUS_ALERTED for alertable waits. Only real failures are errors. Long waits are made as several short ones, see waitSlice.
+func (b *BananaPhone) WaitForSingleObject(handle uintptr, alertable bool, timeout time.Duration) (uint32, error) {
+	sysid, e := b.GetSysID("NtWaitForSingleObject")
+	if e != nil {
+		return 0, e
+	}
+	var a uintptr
+	if alertable {
+		a = 1
+	}
+	var deadline time.Time
+	if timeout >= 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	var interval int64
+	var pin Pinner
+	defer pin.Unpin()
+	pin.Pin(&interval)
+	for {
+		slice := waitSlice
+		if timeout >= 0 {
+			if left := time.Until(deadline); left < slice {
+				slice = left
+			}
+			if slice < 0 {
+				slice = 0
+			}
+		}
+		//relative timeouts are negative, in 100ns units
+		interval = -int64(slice / 100)
+		r, _ := b.Syscall(sysid, handle, a, uintptr(unsafe.Pointer(&interval)))
+		if !NTStatus(r).IsSuccess() {
+			return r, fmt.Errorf("NtWaitForSingleObject failed: %w", NTStatus(r))
+		}
+		if r != ntconst.STATUS_TIMEOUT || (timeout >= 0 && !time.Now().Before(deadline)) {
+			return r, nil
+		}
+	}
+}
+
+//wait is WaitForSingleObject for the Event/Mutant/Semaphore types: true if the object was signalled (or abandoned), false on timeout.
+func (b *BananaPhone) wait(handle uintptr, timeout time.Duration) (bool, error) {
+	r, e := b.WaitForSingleObject(handle, false, timeout)
+	if e != nil {
+		return false, e
+	}
+	return r != ntconst.STATUS_TIMEOUT, nil
+}
+
+//objectAttributesFor returns the attributes for creating or opening a named object, or unnamed ones if name is empty.
+func objectAttributesFor(name string) (*ObjectAttributes, error) {
+	flags := uint32(0)
+	if name != "" {
+		flags = ntconst.OBJ_OPENIF
+	}
+	return NewObjectAttributes(name, flags)
+}
+
+//createSyncObject makes one of the NtCreateEvent/NtCreateMutant/NtCreateSemaphore calls, which all start with a handle out param, access and object attributes.
+func (b *BananaPhone) createSyncObject(fn, name string, access uint32, args ...uintptr) (uintptr, error) {
+	sysid, e := b.GetSysID(fn)
+	if e != nil {
+		return 0, e
+	}
+	oa, e := objectAttributesFor(name)
+	if e != nil {
+		return 0, e
+	}
+	//the args are built before the call, pin them so they stay put until it's done
+	var handle uintptr
+	var pin Pinner
+	defer pin.Unpin()
+	pin.Pin(&handle)
+	pin.Pin(oa)
+	r, _ := b.Syscall(sysid, append([]uintptr{uintptr(unsafe.Pointer(&handle)), uintptr(access), uintptr(unsafe.Pointer(oa))}, args...)...)
+	//STATUS_OBJECT_NAME_EXISTS is a success, the existing object was opened
+	if !NTStatus(r).IsSuccess() {
+		return 0, fmt.Errorf("%s failed: %w", fn, NTStatus(r))
+	}
+	return handle, nil
+}
+
+//openSyncObject makes one of the NtOpenEvent/NtOpenMutant/NtOpenSemaphore calls.
+func (b *BananaPhone) openSyncObject(fn, name string, access uint32) (uintptr, error) {
+	sysid, e := b.GetSysID(fn)
+	if e != nil {
+		return 0, e
+	}
+	oa, e := NewObjectAttributes(name, 0)
+	if e != nil {
+		return 0, e
+	}
+	var handle uintptr
+	var pin Pinner
+	defer pin.Unpin()
+	pin.Pin(&handle)
+	pin.Pin(oa)
+	r, e := b.Syscall(sysid, uintptr(unsafe.Pointer(&handle)), uintptr(access), uintptr(unsafe.Pointer(oa)))
+	if e != nil {
+		return 0, fmt.Errorf("%s failed: %w", fn, NTStatus(r))
+	}
+	return handle, nil
+}
+
+//signal makes a call that takes a handle and an optional out param for the previous state (NtSetEvent, NtReleaseMutant, etc).
+func (b *BananaPhone) signal(fn string, handle uintptr, args ...uintptr) error {
+	sysid, e := b.GetSysID(fn)
+	if e != nil {
+		return e
+	}
+	r, e := b.Syscall(sysid, append([]uintptr{handle}, args...)...)
+	if e != nil {
+		return fmt.Errorf("%s failed: %w", fn, NTStatus(r))
+	}
+	return nil
+}
+
+//Event is an event object. Names are object manager paths, eg \BaseNamedObjects\foo for a global one, or \Sessions\1\BaseNamedObjects\foo for what kernel32 would call Local\foo.
+type Event struct {
+	Handle uintptr
+	b      *BananaPhone
+}
+
+//CreateEvent creates an event using NtCreateEvent, or opens it if an event with the same name already exists. An empty name makes an unnamed event. A manual reset event stays signalled until Reset, otherwise a single waiter is released and it resets itself.
+func (b *BananaPhone) CreateEvent(name string, manualReset, initialState bool) (*Event, error) {
+	typ, state := uintptr(synchronizationEvent), uintptr(0)
+	if manualReset {
+		typ = notificationEvent
+	}
+	if initialState {
+		state = 1
+	}
+	h, e := b.createSyncObject("NtCreateEvent", name, ntconst.EVENT_ALL_ACCESS, typ, state)
+	if e != nil {
+		return nil, e
+	}
+	return &Event{Handle: h, b: b}, nil
+}
+
+//OpenEvent opens an existing named event using NtOpenEvent, with the provided access rights (ntconst.EVENT_*, plus SYNCHRONIZE to wait on it).
+func (b *BananaPhone) OpenEvent(name string, access uint32) (*Event, error) {
+	h, e := b.openSyncObject("NtOpenEvent", name, access)
+	if e != nil {
+		return nil, e
+	}
+	return &Event{Handle: h, b: b}, nil
+}
+
+//Set signals the event using NtSetEvent.
+func (ev *Event) Set() error {
+	return ev.b.signal("NtSetEvent", ev.Handle, 0)
+}
+
+//Reset un-signals the event using NtResetEvent.
+func (ev *Event) Reset() error {
+	return ev.b.signal("NtResetEvent", ev.Handle, 0)
+}
+
+//Wait waits for the event to be signalled, returning false if the timeout (negative for forever) was hit first.
+func (ev *Event) Wait(timeout time.Duration) (bool, error) {
+	return ev.b.wait(ev.Handle, timeout)
+}
+
+//Close closes the event handle.
+func (ev *Event) Close() error {
+	return ev.b.CloseHandle(ev.Handle)
+}
+
+//Mutant is a mutant (mutex) object. See Event about names.
+type Mutant struct {
+	Handle uintptr
+	b      *BananaPhone
+}
+
+//CreateMutant creates a mutant using NtCreateMutant, or opens it if one with the same name already exists. An empty name makes an unnamed mutant. Ownership belongs to the OS thread, so lock the goroutine to its thread (or use an Executor) between acquiring and releasing it.
+func (b *BananaPhone) CreateMutant(name string, initialOwner bool) (*Mutant, error) {
+	owner := uintptr(0)
+	if initialOwner {
+		owner = 1
+	}
+	h, e := b.createSyncObject("NtCreateMutant", name, ntconst.MUTANT_ALL_ACCESS, owner)
+	if e != nil {
+		return nil, e
+	}
+	return &Mutant{Handle: h, b: b}, nil
+}
+
+//OpenMutant opens an existing named mutant using NtOpenMutant, with the provided access rights (ntconst.MUTANT_*, plus SYNCHRONIZE to wait on it).
+func (b *BananaPhone) OpenMutant(name string, access uint32) (*Mutant, error) {
+	h, e := b.openSyncObject("NtOpenMutant", name, access)
+	if e != nil {
+		return nil, e
+	}
+	return &Mutant{Handle: h, b: b}, nil
+}
+
+//Wait acquires the mutant, returning false if the timeout (negative for forever) was hit first. An abandoned mutant (the owner exited without releasing it) is acquired as normal.
+func (m *Mutant) Wait(timeout time.Duration) (bool, error) {
+	return m.b.wait(m.Handle, timeout)
+}
+
+//Release releases the mutant using NtReleaseMutant. It has to be called from the thread that owns it.
+func (m *Mutant) Release() error {
+	return m.b.signal("NtReleaseMutant", m.Handle, 0)
+}
+
+//Close closes the mutant handle.
+func (m *Mutant) Close() error {
+	return m.b.CloseHandle(m.Handle)
+}
+
+//Semaphore is a semaphore object. See Event about names.
+type Semaphore struct {
+	Handle uintptr
+	b      *BananaPhone
+}
+
+//CreateSemaphore creates a semaphore using NtCreateSemaphore, or opens it if one with the same name already exists. An empty name makes an unnamed semaphore.
+func (b *BananaPhone) CreateSemaphore(name string, initial, max int32) (*Semaphore, error) {
+	h, e := b.createSyncObject("NtCreateSemaphore", name, ntconst.SEMAPHORE_ALL_ACCESS, uintptr(initial), uintptr(max))
+	if e != nil {
+		return nil, e
+	}
+	return &Semaphore{Handle: h, b: b}, nil
+}
+
+//OpenSemaphore opens an existing named semaphore using NtOpenSemaphore, with the provided access rights (ntconst.SEMAPHORE_*, plus SYNCHRONIZE to wait on it).
+func (b *BananaPhone) OpenSemaphore(name string, access uint32) (*Semaphore, error) {
+	h, e := b.openSyncObject("NtOpenSemaphore", name, access)
+	if e != nil {
+		return nil, e
+	}
+	return &Semaphore{Handle: h, b: b}, nil
+}
+
+//Wait takes one count from the semaphore, returning false if the timeout (negative for forever) was hit first.
+func (s *Semaphore) Wait(timeout time.Duration) (bool, error) {
+	return s.b.wait(s.Handle, timeout)
+}
+
+//Release adds n to the semaphore's count using NtReleaseSemaphore, returning the previous count.
+func (s *Semaphore) Release(n int32) (int32, error) {
+	var prev int32
+	var pin Pinner
+	defer pin.Unpin()
+	pin.Pin(&prev)
+	e := s.b.signal("NtReleaseSemaphore", s.Handle, uintptr(n), uintptr(unsafe.Pointer(&prev)))
+	return prev, e
+}
+
+//Close closes the semaphore handle.
+func (s *Semaphore) Close() error {
+	return s.b.CloseHandle(s.Handle)
+}
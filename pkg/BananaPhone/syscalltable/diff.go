@@ -0,0 +1,71 @@
+package syscalltable
+
+import (
+	"fmt"
+	"strings"
+)
+
+//Diff is the difference between two tables, see Compare.
+type Diff struct {
+	//Added are in the new table but not the old one, Removed the other way round.
+	Added, Removed []Entry
+	//Changed are in both, with different sysids.
+	Changed []Change
+}
+
+//Change is a syscall whose sysid differs between two tables.
+type Change struct {
+	Name     string
+	Old, New uint16
+}
+
+func (d Diff) String() string {
+	sb := &strings.Builder{}
+	for _, e := range d.Added {
+		fmt.Fprintf(sb, "+ %-50s %#04x\n", e.Name, e.SysID)
+	}
+	for _, e := range d.Removed {
+		fmt.Fprintf(sb, "- %-50s %#04x\n", e.Name, e.SysID)
+	}
+	for _, c := range d.Changed {
+		fmt.Fprintf(sb, "~ %-50s %#04x -> %#04x\n", c.Name, c.Old, c.New)
+	}
+	return sb.String()
+}
+
+//Compare lists the syscalls added and removed between old and new, and every sysid that moved. Entries that couldn't be resolved in either table are only counted as added or removed, never as changed. Everything is in name order.
+func Compare(old, new *Table) Diff {
+	d := Diff{Added: []Entry{}, Removed: []Entry{}, Changed: []Change{}}
+	i, j := 0, 0
+	for i < len(old.Entries) || j < len(new.Entries) {
+		switch {
+		case j >= len(new.Entries) || (i < len(old.Entries) && old.Entries[i].Name < new.Entries[j].Name):
+			d.Removed = append(d.Removed, old.Entries[i])
+			i++
+		case i >= len(old.Entries) || new.Entries[j].Name < old.Entries[i].Name:
+			d.Added = append(d.Added, new.Entries[j])
+			j++
+		default:
+			o, n := old.Entries[i], new.Entries[j]
+			if o.Err == nil && n.Err == nil && o.SysID != n.SysID {
+				d.Changed = append(d.Changed, Change{Name: o.Name, Old: o.SysID, New: n.SysID})
+			}
+			i++
+			j++
+		}
+	}
+	return d
+}
+
+//CompareNtdll builds the tables of the two files and compares them.
+func CompareNtdll(oldPath, newPath string) (Diff, error) {
+	old, e := FromFile(oldPath)
+	if e != nil {
+		return Diff{}, fmt.Errorf("%s: %w", oldPath, e)
+	}
+	new, e := FromFile(newPath)
+	if e != nil {
+		return Diff{}, fmt.Errorf("%s: %w", newPath, e)
+	}
+	return Compare(old, new), nil
+}
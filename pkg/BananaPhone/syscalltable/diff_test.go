@@ -0,0 +1,61 @@
+package syscalltable
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	broken := errors.New("broken stub")
+	old := &Table{Entries: []Entry{
+		{Name: "NtA", SysID: 1},
+		{Name: "NtB", SysID: 2},
+		{Name: "NtC", SysID: 3},
+		{Name: "NtErr", Err: broken},
+		{Name: "NtGone", SysID: 5},
+	}}
+	new := &Table{Entries: []Entry{
+		{Name: "NtA", SysID: 1},
+		{Name: "NtB", SysID: 7},
+		{Name: "NtC", Err: broken},
+		{Name: "NtErr", SysID: 9},
+		{Name: "NtNew", SysID: 8},
+	}}
+	d := Compare(old, new)
+	want := Diff{
+		Added:   []Entry{{Name: "NtNew", SysID: 8}},
+		Removed: []Entry{{Name: "NtGone", SysID: 5}},
+		//NtC and NtErr failed on one side, so they didn't change
+		Changed: []Change{{Name: "NtB", Old: 2, New: 7}},
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("got %+v, want %+v", d, want)
+	}
+
+	wantStr := "+ NtNew" + strings.Repeat(" ", 45) + " 0x0008\n" +
+		"- NtGone" + strings.Repeat(" ", 44) + " 0x0005\n" +
+		"~ NtB" + strings.Repeat(" ", 47) + " 0x0002 -> 0x0007\n"
+	if s := d.String(); s != wantStr {
+		t.Errorf("String: got\n%q\nwant\n%q", s, wantStr)
+	}
+
+	//everything at the ends of either table
+	d = Compare(&Table{}, old)
+	if len(d.Added) != len(old.Entries) || len(d.Removed) != 0 || len(d.Changed) != 0 {
+		t.Errorf("from empty: got %+v", d)
+	}
+	d = Compare(old, &Table{})
+	if len(d.Removed) != len(old.Entries) || len(d.Added) != 0 || len(d.Changed) != 0 {
+		t.Errorf("to empty: got %+v", d)
+	}
+
+	d = Compare(old, old)
+	if d.Added == nil || d.Removed == nil || d.Changed == nil {
+		t.Errorf("identical tables: got nil slices in %+v", d)
+	}
+	if s := d.String(); s != "" {
+		t.Errorf("identical tables: got %q", s)
+	}
+}
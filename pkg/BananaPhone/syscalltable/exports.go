@@ -0,0 +1,114 @@
+package syscalltable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/Binject/debug/pe"
+)
+
+//export is a named export. pe.File.Exports only understands x64 and x86 images (it picks the optional header by machine type), so the export directory is read here instead.
+type export struct {
+	Name           string
+	Ordinal        uint32
+	VirtualAddress uint32
+}
+
+//exports reads the named exports of p, checking every table against the image as it goes.
+func exports(p *pe.File) ([]export, error) {
+	var dd pe.DataDirectory
+	switch oh := p.OptionalHeader.(type) {
+	case *pe.OptionalHeader64:
+		if oh.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_EXPORT {
+			dd = oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_EXPORT]
+		}
+	case *pe.OptionalHeader32:
+		if oh.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_EXPORT {
+			dd = oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_EXPORT]
+		}
+	}
+	if dd.VirtualAddress == 0 {
+		return nil, fmt.Errorf("module has no exports")
+	}
+	dir, e := readRVA(p, dd.VirtualAddress, 40)
+	if e != nil {
+		return nil, fmt.Errorf("reading export directory: %w", e)
+	}
+	base := binary.LittleEndian.Uint32(dir[16:])
+	nfuncs := binary.LittleEndian.Uint32(dir[20:])
+	nnames := binary.LittleEndian.Uint32(dir[24:])
+	funcs, e := readRVA(p, binary.LittleEndian.Uint32(dir[28:]), int(nfuncs)*4)
+	if e != nil {
+		return nil, fmt.Errorf("reading export address table: %w", e)
+	}
+	names, e := readRVA(p, binary.LittleEndian.Uint32(dir[32:]), int(nnames)*4)
+	if e != nil {
+		return nil, fmt.Errorf("reading export name table: %w", e)
+	}
+	ords, e := readRVA(p, binary.LittleEndian.Uint32(dir[36:]), int(nnames)*2)
+	if e != nil {
+		return nil, fmt.Errorf("reading export ordinal table: %w", e)
+	}
+
+	ret := make([]export, 0, nnames)
+	for i := uint32(0); i < nnames; i++ {
+		idx := uint32(binary.LittleEndian.Uint16(ords[i*2:]))
+		if idx >= nfuncs {
+			return nil, fmt.Errorf("export %d has function index %d, there are only %d", i, idx, nfuncs)
+		}
+		name, e := readString(p, binary.LittleEndian.Uint32(names[i*4:]))
+		if e != nil {
+			return nil, fmt.Errorf("reading export name %d: %w", i, e)
+		}
+		ret = append(ret, export{Name: name, Ordinal: base + idx, VirtualAddress: binary.LittleEndian.Uint32(funcs[idx*4:])})
+	}
+	return ret, nil
+}
+
+//sectionForRVA returns the section that contains the provided RVA, or nil if there isn't one.
+func sectionForRVA(p *pe.File, rva uint32) *pe.Section {
+	for _, s := range p.Sections {
+		if rva >= s.VirtualAddress && rva-s.VirtualAddress < s.VirtualSize {
+			return s
+		}
+	}
+	return nil
+}
+
+//readRVA reads n bytes at the provided RVA.
+func readRVA(p *pe.File, rva uint32, n int) ([]byte, error) {
+	s := sectionForRVA(p, rva)
+	if s == nil {
+		return nil, fmt.Errorf("rva %#x is not in any section", rva)
+	}
+	if uint64(rva-s.VirtualAddress)+uint64(n) > uint64(s.Size) {
+		return nil, fmt.Errorf("%#x bytes at rva %#x runs off the end of %s", n, rva, s.Name)
+	}
+	buf := make([]byte, n)
+	if _, e := s.ReadAt(buf, int64(rva-s.VirtualAddress)); e != nil {
+		return nil, e
+	}
+	return buf, nil
+}
+
+//readString reads the nul terminated string at rva.
+func readString(p *pe.File, rva uint32) (string, error) {
+	s := sectionForRVA(p, rva)
+	if s == nil {
+		return "", fmt.Errorf("rva %#x is not in any section", rva)
+	}
+	ret := []byte{}
+	chunk := make([]byte, 64)
+	for off := int64(rva - s.VirtualAddress); off < int64(s.Size); off += int64(len(chunk)) {
+		n, _ := s.ReadAt(chunk, off)
+		if i := bytes.IndexByte(chunk[:n], 0); i >= 0 {
+			return string(append(ret, chunk[:i]...)), nil
+		}
+		if n == 0 {
+			break
+		}
+		ret = append(ret, chunk[:n]...)
+	}
+	return "", fmt.Errorf("string at rva %#x runs off the end of %s", rva, s.Name)
+}
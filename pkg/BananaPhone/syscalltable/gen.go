@@ -0,0 +1,47 @@
+package syscalltable
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+
+	"github.com/Binject/debug/pe"
+)
+
+//machineName is the short name of the machine types the package understands.
+func machineName(m uint16) string {
+	switch m {
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		return "x64"
+	case pe.IMAGE_FILE_MACHINE_I386:
+		return "x86"
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		return "arm64"
+	}
+	return fmt.Sprintf("machine %#x", m)
+}
+
+//WriteGo writes a Go source file to w declaring the table as a map[string]uint16 variable called name in package pkg, ready to be compiled into an agent and passed to bananaphone.WithSysIDOverrides for the build the table came from. Entries that couldn't be resolved are left out.
+func (t *Table) WriteGo(w io.Writer, pkg, name string) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by syscalltable; DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	desc := machineName(t.Machine)
+	if t.VersionErr == nil {
+		desc = t.Version.String() + " " + desc
+	}
+	fmt.Fprintf(&buf, "//%s are the sysids from %s (TimeDateStamp %#08x), for use with bananaphone.WithSysIDOverrides.\n", name, desc, t.TimeDateStamp)
+	fmt.Fprintf(&buf, "var %s = map[string]uint16{\n", name)
+	for _, ent := range t.Entries {
+		if ent.Err == nil {
+			fmt.Fprintf(&buf, "\t%q: %#04x,\n", ent.Name, ent.SysID)
+		}
+	}
+	buf.WriteString("}\n")
+	src, e := format.Source(buf.Bytes())
+	if e != nil {
+		return fmt.Errorf("generated bad source: %v", e)
+	}
+	_, e = w.Write(src)
+	return e
+}
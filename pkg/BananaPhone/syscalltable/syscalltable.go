@@ -0,0 +1,178 @@
+//Package syscalltable builds syscall tables from ntdll (or win32u) files offline, without touching the running process, so tables for any number of builds can be produced on any platform. x64, x86 and arm64 images are understood.
+package syscalltable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/Binject/debug/pe"
+	bananaphone "github.com/C-Sto/BananaPhone/pkg/BananaPhone"
+)
+
+//ErrUnknownStub is set on an Entry whose stub doesn't look like a syscall stub for the image's architecture.
+var ErrUnknownStub = errors.New("unrecognised syscall stub")
+
+//ErrUnsupportedMachine is returned for images that aren't x64, x86 or arm64.
+var ErrUnsupportedMachine = errors.New("unsupported machine type")
+
+//Table is the syscall table of one module.
+type Table struct {
+	//Machine is the image's pe.IMAGE_FILE_MACHINE_* value.
+	Machine       uint16
+	TimeDateStamp uint32
+	//Version is the file version from the image's version resource, if it has one (VersionErr says why not otherwise).
+	Version    bananaphone.FileVersion
+	VersionErr error
+	//Entries are sorted by name.
+	Entries []Entry
+}
+
+//Entry is a single syscall export. Both the Nt* and Zw* names are listed.
+type Entry struct {
+	Name    string
+	Ordinal uint32
+	RVA     uint32
+	SysID   uint16
+	//Err is set (and SysID is 0) if the stub couldn't be read.
+	Err error
+}
+
+//FromFile builds the table for the module at path.
+func FromFile(path string) (*Table, error) {
+	b, e := ioutil.ReadFile(path)
+	if e != nil {
+		return nil, e
+	}
+	return FromBytes(b)
+}
+
+//FromBytes builds the table for a module in its on-disk layout.
+func FromBytes(b []byte) (t *Table, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			t, err = nil, fmt.Errorf("malformed image: %v", r)
+		}
+	}()
+	//binject/debug refuses machine types it doesn't know, which includes arm64. The layout is the same PE32+ as x64 though, so parse a copy claiming to be x64 and remember what it really is.
+	machine := uint16(0)
+	if len(b) > 0x40 {
+		if off := int(binary.LittleEndian.Uint32(b[0x3c:])); off >= 0 && off+6 <= len(b) && binary.LittleEndian.Uint16(b[off+4:]) == pe.IMAGE_FILE_MACHINE_ARM64 {
+			machine = pe.IMAGE_FILE_MACHINE_ARM64
+			b = append([]byte(nil), b...)
+			binary.LittleEndian.PutUint16(b[off+4:], pe.IMAGE_FILE_MACHINE_AMD64)
+		}
+	}
+	p, e := pe.NewFile(bytes.NewReader(b))
+	if e != nil {
+		return nil, e
+	}
+	if machine == 0 {
+		machine = p.Machine
+	}
+	return fromPE(p, machine)
+}
+
+//FromPE builds the table for an already parsed module.
+func FromPE(p *pe.File) (*Table, error) {
+	return fromPE(p, p.Machine)
+}
+
+//fromPE builds the table, reading the stubs as machine.
+func fromPE(p *pe.File, machine uint16) (*Table, error) {
+	var sysid func([]byte) (uint16, bool)
+	switch machine {
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		sysid = sysIDx64
+	case pe.IMAGE_FILE_MACHINE_I386:
+		sysid = sysIDx86
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		sysid = sysIDarm64
+	default:
+		return nil, fmt.Errorf("%w: %#x", ErrUnsupportedMachine, machine)
+	}
+	exports, e := exports(p)
+	if e != nil {
+		return nil, e
+	}
+
+	t := &Table{Machine: machine, TimeDateStamp: p.FileHeader.TimeDateStamp, Entries: []Entry{}}
+	t.Version, t.VersionErr = bananaphone.PEFileVersion(p)
+
+	//every syscall stub is exported as a Zw* and Nt* pair, other Nt* exports (eg NtdllDefWindowProc_A) aren't syscalls
+	zw := map[string]bool{}
+	for _, ex := range exports {
+		if strings.HasPrefix(ex.Name, "Zw") {
+			zw[ex.Name[2:]] = true
+		}
+	}
+	for _, ex := range exports {
+		if !(strings.HasPrefix(ex.Name, "Nt") || strings.HasPrefix(ex.Name, "Zw")) || !zw[ex.Name[2:]] {
+			continue
+		}
+		ent := Entry{Name: ex.Name, Ordinal: ex.Ordinal, RVA: ex.VirtualAddress}
+		stub, e := readRVA(p, ex.VirtualAddress, 8)
+		if e != nil {
+			ent.Err = e
+		} else if id, ok := sysid(stub); ok {
+			ent.SysID = id
+		} else {
+			ent.Err = fmt.Errorf("%w: % x", ErrUnknownStub, stub)
+		}
+		t.Entries = append(t.Entries, ent)
+	}
+	sort.Slice(t.Entries, func(i, j int) bool { return t.Entries[i].Name < t.Entries[j].Name })
+	return t, nil
+}
+
+//Lookup returns the sysid of name.
+func (t *Table) Lookup(name string) (uint16, bool) {
+	i := sort.Search(len(t.Entries), func(i int) bool { return t.Entries[i].Name >= name })
+	if i < len(t.Entries) && t.Entries[i].Name == name && t.Entries[i].Err == nil {
+		return t.Entries[i].SysID, true
+	}
+	return 0, false
+}
+
+//sysIDx64 reads mov r10, rcx; mov eax, sysid.
+func sysIDx64(b []byte) (uint16, bool) {
+	if !bytes.HasPrefix(b, bananaphone.HookCheck) {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint16(b[4:]), true
+}
+
+//sysIDx86 reads mov eax, sysid.
+func sysIDx86(b []byte) (uint16, bool) {
+	if b[0] != 0xb8 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint16(b[1:]), true
+}
+
+//sysIDarm64 reads svc #sysid.
+func sysIDarm64(b []byte) (uint16, bool) {
+	insn := binary.LittleEndian.Uint32(b)
+	if insn&0xffe0001f != 0xd4000001 {
+		return 0, false
+	}
+	return uint16(insn >> 5), true
+}
+
+//Feed returns the table as a single build bananaphone.SysIDFeed, keyed by the build number from the version resource (eg 19041 for every Windows 10 2004-22H2 ntdll), which is what WithSysIDFeed looks tables up by. Merge these into a feed that's pushed out to agents. It's nil if the image had no version.
+func (t *Table) Feed() bananaphone.SysIDFeed {
+	if t.VersionErr != nil {
+		return nil
+	}
+	ids := make(map[string]uint16, len(t.Entries))
+	for _, ent := range t.Entries {
+		if ent.Err == nil {
+			ids[ent.Name] = ent.SysID
+		}
+	}
+	return bananaphone.SysIDFeed{uint32(t.Version.Build): ids}
+}
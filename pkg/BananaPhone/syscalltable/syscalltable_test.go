@@ -0,0 +1,215 @@
+package syscalltable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/Binject/debug/pe"
+)
+
+func TestStubSysID(t *testing.T) {
+	tests := []struct {
+		name   string
+		sysid  func([]byte) (uint16, bool)
+		stub   []byte
+		want   uint16
+		wantOK bool
+	}{
+		{"x64", sysIDx64, []byte{0x4c, 0x8b, 0xd1, 0xb8, 0x26, 0x00, 0x00, 0x00}, 0x26, true},
+		{"x64 high", sysIDx64, []byte{0x4c, 0x8b, 0xd1, 0xb8, 0x34, 0x12, 0x00, 0x00}, 0x1234, true},
+		{"x64 hooked", sysIDx64, []byte{0xe9, 0x8b, 0xd1, 0xb8, 0x26, 0x00, 0x00, 0x00}, 0, false},
+		{"x64 x86 stub", sysIDx64, []byte{0xb8, 0x26, 0x00, 0x00, 0x00, 0xba, 0x00, 0x00}, 0, false},
+		{"x86", sysIDx86, []byte{0xb8, 0x26, 0x00, 0x00, 0x00, 0xba, 0x00, 0x00}, 0x26, true},
+		{"x86 high", sysIDx86, []byte{0xb8, 0x34, 0x12, 0x00, 0x00, 0xba, 0x00, 0x00}, 0x1234, true},
+		{"x86 hooked", sysIDx86, []byte{0xe9, 0x26, 0x00, 0x00, 0x00, 0xba, 0x00, 0x00}, 0, false},
+		//svc #0x26; ret
+		{"arm64", sysIDarm64, []byte{0xc1, 0x04, 0x00, 0xd4, 0xc0, 0x03, 0x5f, 0xd6}, 0x26, true},
+		//svc #0xffff, the whole imm16
+		{"arm64 max", sysIDarm64, []byte{0xe1, 0xff, 0x1f, 0xd4, 0xc0, 0x03, 0x5f, 0xd6}, 0xffff, true},
+		//hvc #0x26 only differs in the low bits
+		{"arm64 hvc", sysIDarm64, []byte{0xc2, 0x04, 0x00, 0xd4, 0xc0, 0x03, 0x5f, 0xd6}, 0, false},
+		//brk #0x26 only differs in the high bits
+		{"arm64 brk", sysIDarm64, []byte{0xc0, 0x04, 0x20, 0xd4, 0xc0, 0x03, 0x5f, 0xd6}, 0, false},
+		{"arm64 ret", sysIDarm64, []byte{0xc0, 0x03, 0x5f, 0xd6, 0xc0, 0x03, 0x5f, 0xd6}, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := tt.sysid(tt.stub)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s: got %#x, %v, want %#x, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+//testImage builds a minimal dll for machine with a single section holding the export table and one 16 byte stub per export. It's only as complete as FromBytes needs.
+func testImage(machine uint16, timestamp uint32, stubs map[string][]byte) []byte {
+	const (
+		lfanew   = 0x80
+		sectRVA  = 0x1000
+		sectOff  = 0x200
+		sectSize = 0x1000
+		dirOff   = 0x400
+	)
+	names := make([]string, 0, len(stubs))
+	for n := range stubs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	sect := make([]byte, sectSize)
+	for i, n := range names {
+		copy(sect[i*16:], stubs[n])
+	}
+	eat := dirOff + 40
+	ent := eat + 4*len(names)
+	eot := ent + 4*len(names)
+	str := eot + 2*len(names)
+	le := binary.LittleEndian
+	for i, n := range names {
+		le.PutUint32(sect[eat+4*i:], uint32(sectRVA+i*16))
+		le.PutUint32(sect[ent+4*i:], uint32(sectRVA+str))
+		le.PutUint16(sect[eot+2*i:], uint16(i))
+		str += copy(sect[str:], n) + 1
+	}
+	le.PutUint32(sect[dirOff+16:], 1) //Base
+	le.PutUint32(sect[dirOff+20:], uint32(len(names)))
+	le.PutUint32(sect[dirOff+24:], uint32(len(names)))
+	le.PutUint32(sect[dirOff+28:], uint32(sectRVA+eat))
+	le.PutUint32(sect[dirOff+32:], uint32(sectRVA+ent))
+	le.PutUint32(sect[dirOff+36:], uint32(sectRVA+eot))
+	exportDir := pe.DataDirectory{VirtualAddress: sectRVA + dirOff, Size: uint32(str - dirOff)}
+
+	buf := &bytes.Buffer{}
+	hdr := make([]byte, lfanew)
+	copy(hdr, "MZ")
+	le.PutUint32(hdr[0x3c:], lfanew)
+	buf.Write(hdr)
+	buf.WriteString("PE\x00\x00")
+	var opt interface{}
+	if machine == pe.IMAGE_FILE_MACHINE_I386 {
+		oh := &pe.OptionalHeader32{Magic: 0x10b, ImageBase: 0x10000000}
+		oh.SectionAlignment, oh.FileAlignment, oh.SizeOfImage, oh.SizeOfHeaders = sectRVA, sectOff, sectRVA+sectSize, sectOff
+		oh.NumberOfRvaAndSizes = 16
+		oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_EXPORT] = exportDir
+		opt = oh
+	} else {
+		oh := &pe.OptionalHeader64{Magic: 0x20b, ImageBase: 0x180000000}
+		oh.SectionAlignment, oh.FileAlignment, oh.SizeOfImage, oh.SizeOfHeaders = sectRVA, sectOff, sectRVA+sectSize, sectOff
+		oh.NumberOfRvaAndSizes = 16
+		oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_EXPORT] = exportDir
+		opt = oh
+	}
+	binary.Write(buf, le, pe.FileHeader{
+		Machine:              machine,
+		NumberOfSections:     1,
+		TimeDateStamp:        timestamp,
+		SizeOfOptionalHeader: uint16(binary.Size(opt)),
+		Characteristics:      0x2022,
+	})
+	binary.Write(buf, le, opt)
+	sh := pe.SectionHeader32{
+		VirtualSize:      sectSize,
+		VirtualAddress:   sectRVA,
+		SizeOfRawData:    sectSize,
+		PointerToRawData: sectOff,
+		Characteristics:  0x60000020,
+	}
+	copy(sh.Name[:], ".text")
+	binary.Write(buf, le, sh)
+	buf.Write(make([]byte, sectOff-buf.Len()))
+	buf.Write(sect)
+	return buf.Bytes()
+}
+
+func TestFromBytes(t *testing.T) {
+	nop := bytes.Repeat([]byte{0x90}, 8)
+	tests := []struct {
+		machine uint16
+		stub    func(id uint16) []byte
+	}{
+		{pe.IMAGE_FILE_MACHINE_AMD64, func(id uint16) []byte {
+			return []byte{0x4c, 0x8b, 0xd1, 0xb8, byte(id), byte(id >> 8), 0x00, 0x00, 0x0f, 0x05, 0xc3}
+		}},
+		{pe.IMAGE_FILE_MACHINE_I386, func(id uint16) []byte {
+			return []byte{0xb8, byte(id), byte(id >> 8), 0x00, 0x00, 0xba, 0x00, 0x00, 0x00, 0x00}
+		}},
+		{pe.IMAGE_FILE_MACHINE_ARM64, func(id uint16) []byte {
+			insn := make([]byte, 8)
+			binary.LittleEndian.PutUint32(insn, 0xd4000001|uint32(id)<<5)
+			binary.LittleEndian.PutUint32(insn[4:], 0xd65f03c0)
+			return insn
+		}},
+	}
+	for _, tt := range tests {
+		img := testImage(tt.machine, 0x5f5e100, map[string][]byte{
+			"NtClose":       tt.stub(0x0f),
+			"ZwClose":       tt.stub(0x0f),
+			"NtOpenProcess": tt.stub(0x26),
+			"ZwOpenProcess": tt.stub(0x26),
+			"NtBroken":      nop,
+			"ZwBroken":      nop,
+			//not syscalls: no Zw twin, and not Nt/Zw at all
+			"NtdllDefWindowProc_A": nop,
+			"RtlFoo":               tt.stub(0x01),
+		})
+		orig := append([]byte(nil), img...)
+		tab, e := FromBytes(img)
+		if e != nil {
+			t.Errorf("%#x: %v", tt.machine, e)
+			continue
+		}
+		if !bytes.Equal(img, orig) {
+			t.Errorf("%#x: FromBytes modified its input", tt.machine)
+		}
+		if tab.Machine != tt.machine {
+			t.Errorf("%#x: got machine %#x", tt.machine, tab.Machine)
+		}
+		if tab.TimeDateStamp != 0x5f5e100 {
+			t.Errorf("%#x: got TimeDateStamp %#x", tt.machine, tab.TimeDateStamp)
+		}
+		if tab.VersionErr == nil {
+			t.Errorf("%#x: got version %v from an image without a version resource", tt.machine, tab.Version)
+		}
+		want := []Entry{
+			{Name: "NtBroken"},
+			{Name: "NtClose", SysID: 0x0f},
+			{Name: "NtOpenProcess", SysID: 0x26},
+			{Name: "ZwBroken"},
+			{Name: "ZwClose", SysID: 0x0f},
+			{Name: "ZwOpenProcess", SysID: 0x26},
+		}
+		if len(tab.Entries) != len(want) {
+			t.Errorf("%#x: got %d entries, want %d: %+v", tt.machine, len(tab.Entries), len(want), tab.Entries)
+			continue
+		}
+		for i, w := range want {
+			got := tab.Entries[i]
+			if got.Name != w.Name || got.SysID != w.SysID {
+				t.Errorf("%#x: entry %d: got %s %#x, want %s %#x", tt.machine, i, got.Name, got.SysID, w.Name, w.SysID)
+			}
+			if broken := w.Name[2:] == "Broken"; broken != errors.Is(got.Err, ErrUnknownStub) {
+				t.Errorf("%#x: %s: got err %v", tt.machine, got.Name, got.Err)
+			}
+		}
+		if id, ok := tab.Lookup("NtOpenProcess"); !ok || id != 0x26 {
+			t.Errorf("%#x: Lookup(NtOpenProcess): got %#x, %v", tt.machine, id, ok)
+		}
+		if _, ok := tab.Lookup("NtBroken"); ok {
+			t.Errorf("%#x: Lookup(NtBroken) found an entry that didn't resolve", tt.machine)
+		}
+	}
+}
+
+func TestFromPEUnsupportedMachine(t *testing.T) {
+	//binject/debug won't parse an arm32 image at all, so relabel one it did parse
+	p, e := pe.NewFile(bytes.NewReader(testImage(pe.IMAGE_FILE_MACHINE_AMD64, 0, map[string][]byte{"NtClose": nil, "ZwClose": nil})))
+	if e != nil {
+		t.Fatal(e)
+	}
+	p.Machine = pe.IMAGE_FILE_MACHINE_ARMNT
+	if _, e = FromPE(p); !errors.Is(e, ErrUnsupportedMachine) {
+		t.Errorf("got %v, want ErrUnsupportedMachine", e)
+	}
+}
@@ -0,0 +1,177 @@
+package bananaphone
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//TableFormat is the serialization used by ExportTable.
+type TableFormat int
+
+const (
+	//TableJSON writes the table as a JSON array of SyscallEntry objects.
+	TableJSON TableFormat = iota
+	//TableCSV writes the table as CSV with a header row, columns in SyscallEntry field order.
+	TableCSV
+)
+
+//SyscallEntry is a single row of the resolved syscall table.
+type SyscallEntry struct {
+	Name    string `json:"name"`
+	Ordinal uint32 `json:"ordinal"`
+	SysID   uint16 `json:"ssn"`
+	RVA     uint32 `json:"rva"`
+	//Source is where the sysid came from, as in Resolution.Source.
+	Source string `json:"source"`
+	//Hooked is true if the stub in the module the phone was created against didn't start with HookCheck.
+	Hooked bool `json:"hooked"`
+	//Error is set (and SysID is 0) if the sysid couldn't be resolved.
+	Error string `json:"error,omitempty"`
+}
+
+//SyscallTable resolves every syscall exported by the phone's module (every Nt* and Zw* export that has a Zw* twin) and returns them sorted by name. Hook status is checked before anything is resolved, so an Auto mode fallback to disk part way through doesn't hide it.
+func (b *BananaPhone) SyscallTable() ([]SyscallEntry, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	exports, e := b.getExports()
+	if e != nil {
+		return nil, e
+	}
+
+	//every syscall stub is exported as a Zw* and Nt* pair, other Nt* exports (eg NtdllDefWindowProc_A) aren't syscalls
+	zw := map[string]bool{}
+	for _, ex := range exports {
+		if strings.HasPrefix(ex.Name, "Zw") {
+			zw[ex.Name[2:]] = true
+		}
+	}
+	ret := []SyscallEntry{}
+	for _, ex := range exports {
+		if !(strings.HasPrefix(ex.Name, "Nt") || strings.HasPrefix(ex.Name, "Zw")) || !zw[ex.Name[2:]] {
+			continue
+		}
+		stub, e := readRVA(b.banana, ex.VirtualAddress, len(HookCheck))
+		ret = append(ret, SyscallEntry{
+			Name:    ex.Name,
+			Ordinal: ex.Ordinal,
+			RVA:     ex.VirtualAddress,
+			Hooked:  e != nil || !bytes.Equal(stub, HookCheck),
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+
+	for i := range ret {
+		ent := &ret[i]
+		if id, ok := b.overrides[ent.Name]; ok {
+			ent.SysID = id
+			ent.Source = "override"
+			continue
+		}
+		var r Resolution
+		if id, ok := b.cachedSysID(ent.Name); ok {
+			r = b.cachedResolution(ent.Name, id)
+		} else {
+			r, e = b.resolveSysID(ent.Name)
+			if e != nil {
+				ent.Error = e.Error()
+				continue
+			}
+			b.storeResolution(ent.Name, r)
+		}
+		ent.SysID = r.SysID
+		ent.Source = r.Source
+	}
+	b.savePersistentCache()
+	return ret, nil
+}
+
+//ExportTable writes the phone's SyscallTable to w in the requested format, for building offline tables or comparing hosts.
+func (b *BananaPhone) ExportTable(w io.Writer, format TableFormat) error {
+	table, e := b.SyscallTable()
+	if e != nil {
+		return e
+	}
+	switch format {
+	case TableJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(table)
+	case TableCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"name", "ordinal", "ssn", "rva", "source", "hooked", "error"})
+		for _, ent := range table {
+			cw.Write([]string{
+				ent.Name,
+				strconv.FormatUint(uint64(ent.Ordinal), 10),
+				strconv.FormatUint(uint64(ent.SysID), 10),
+				fmt.Sprintf("%#x", ent.RVA),
+				ent.Source,
+				strconv.FormatBool(ent.Hooked),
+				ent.Error,
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown table format: %d", format)
+}
+
+//FoundExport is an export returned by FindExports.
+type FoundExport struct {
+	Name    string
+	Ordinal uint32
+	RVA     uint32
+	//IsSyscall is true if the export is a syscall stub, in which case SysID and Source are filled in (or Err, if it couldn't be resolved).
+	IsSyscall bool
+	SysID     uint16
+	Source    string
+	Err       error
+}
+
+//FindExports returns every named export of the phone's module that match accepts, sorted by name, eg everything starting with NtAlpc. Syscalls among them are resolved (and cached) the same way GetSysID would.
+func (b *BananaPhone) FindExports(match func(name string) bool) ([]FoundExport, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	exports, e := b.getExports()
+	if e != nil {
+		return nil, e
+	}
+	ret := []FoundExport{}
+	resolved := false
+	for _, ex := range exports {
+		if ex.Name == "" || !match(ex.Name) {
+			continue
+		}
+		f := FoundExport{Name: ex.Name, Ordinal: ex.Ordinal, RVA: ex.VirtualAddress}
+		if id, ok := b.overrides[ex.Name]; ok {
+			f.IsSyscall, f.SysID, f.Source = true, id, "override"
+		} else if f.IsSyscall, _ = b.isSyscall(ex.VirtualAddress); f.IsSyscall {
+			var r Resolution
+			if id, ok := b.cachedSysID(ex.Name); ok {
+				r = b.cachedResolution(ex.Name, id)
+			} else if r, f.Err = b.resolveSysID(ex.Name); f.Err == nil {
+				b.storeResolution(ex.Name, r)
+				resolved = true
+			}
+			f.SysID, f.Source = r.SysID, r.Source
+		}
+		ret = append(ret, f)
+	}
+	if resolved {
+		b.savePersistentCache()
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+//FindExportsRegexp is FindExports with the names matched against re.
+func (b *BananaPhone) FindExportsRegexp(re *regexp.Regexp) ([]FoundExport, error) {
+	return b.FindExports(re.MatchString)
+}
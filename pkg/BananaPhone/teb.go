@@ -0,0 +1,51 @@
+package bananaphone
+
+//NtTib is the NT_TIB structure found at the start of the TEB.
+type NtTib struct {
+	ExceptionList        uintptr
+	StackBase            uintptr
+	StackLimit           uintptr
+	SubSystemTib         uintptr
+	FiberData            uintptr
+	ArbitraryUserPointer uintptr
+	Self                 uintptr
+}
+
+//TEB is the start of the x64 Thread Environment Block. The rest of the structure is huge and mostly boring, the few interesting fields further in have their own accessors (eg TEBTlsSlots). Get a copy with ReadTEB.
+type TEB struct {
+	NtTib                        NtTib
+	EnvironmentPointer           uintptr
+	ClientID                     ClientID
+	ActiveRpcHandle              uintptr
+	ThreadLocalStoragePointer    uintptr
+	ProcessEnvironmentBlock      uintptr
+	LastErrorValue               uint32
+	CountOfOwnedCriticalSections uint32
+}
+
+const (
+	tebLastErrorOffset  = 0x68
+	tebLastStatusOffset = 0x1250
+	tebTlsSlotsOffset   = 0x1480
+	tebTlsSlotsCount    = 64
+)
+
+//StackBase returns the top (highest address) of the thread's stack.
+func (t TEB) StackBase() uintptr {
+	return t.NtTib.StackBase
+}
+
+//StackLimit returns the lowest committed address of the thread's stack.
+func (t TEB) StackLimit() uintptr {
+	return t.NtTib.StackLimit
+}
+
+//PID returns the process id from the TEB ClientId.
+func (t TEB) PID() uint32 {
+	return uint32(t.ClientID.UniqueProcess)
+}
+
+//TID returns the thread id from the TEB ClientId.
+func (t TEB) TID() uint32 {
+	return uint32(t.ClientID.UniqueThread)
+}
@@ -0,0 +1,71 @@
+package bananaphone
+
+import "unsafe"
+
+const (
+	systemProcessInformationClass = 5
+	//systemProcessInformationSize is the size of SYSTEM_PROCESS_INFORMATION on x64. The thread entries start straight after it.
+	systemProcessInformationSize = 0x100
+)
+
+//systemProcessInformation is the start of SYSTEM_PROCESS_INFORMATION, as far as the fields we need.
+type systemProcessInformation struct {
+	NextEntryOffset uint32
+	NumberOfThreads uint32
+	_               [6]int64 //WorkingSetPrivateSize to KernelTime
+	ImageName       stupidstring
+	BasePriority    int32
+	_               uint32
+	UniqueProcessID uintptr
+}
+
+//SystemThread is a single thread entry (SYSTEM_THREAD_INFORMATION) from NtQuerySystemInformation(SystemProcessInformation). StartAddress is the address the thread was created with, which for most user mode threads is ntdll!RtlUserThreadStart rather than the thread's own function. ThreadState is a KTHREAD_STATE (5 is Waiting), and WaitReason a KWAIT_REASON, if it's waiting.
+type SystemThread struct {
+	KernelTime      int64
+	UserTime        int64
+	CreateTime      int64
+	WaitTime        uint32
+	_               uint32
+	StartAddress    uintptr
+	UniqueProcess   uintptr
+	UniqueThread    uintptr
+	Priority        int32
+	BasePriority    int32
+	ContextSwitches uint32
+	ThreadState     uint32
+	WaitReason      uint32
+	_               uint32
+}
+
+//ListThreads enumerates the threads of the process with the provided pid using NtQuerySystemInformation(SystemProcessInformation). A pid of 0 returns every thread on the system.
+func (b *BananaPhone) ListThreads(pid uint32) ([]SystemThread, error) {
+	sysid, e := b.GetSysID("NtQuerySystemInformation")
+	if e != nil {
+		return nil, e
+	}
+
+	buf, e := b.querySystemInformation(sysid, systemProcessInformationClass, 0x40000)
+	if e != nil {
+		return nil, e
+	}
+
+	entrySize := unsafe.Sizeof(SystemThread{})
+	ret := []SystemThread{}
+	for off := uintptr(0); off+systemProcessInformationSize <= uintptr(len(buf)); {
+		proc := (*systemProcessInformation)(unsafe.Pointer(&buf[off]))
+		if pid == 0 || proc.UniqueProcessID == uintptr(pid) {
+			for i := uintptr(0); i < uintptr(proc.NumberOfThreads); i++ {
+				t := off + systemProcessInformationSize + i*entrySize
+				if t+entrySize > uintptr(len(buf)) {
+					break
+				}
+				ret = append(ret, *(*SystemThread)(unsafe.Pointer(&buf[t])))
+			}
+		}
+		if proc.NextEntryOffset == 0 {
+			break
+		}
+		off += uintptr(proc.NextEntryOffset)
+	}
+	return ret, nil
+}
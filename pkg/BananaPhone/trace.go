@@ -0,0 +1,101 @@
+package bananaphone
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+//TraceEventKind says what a TraceEvent is reporting.
+type TraceEventKind int
+
+const (
+	//TraceResolved is sent when a sysid has been resolved (not for cache hits). Detail says where from ("memory" or "disk").
+	TraceResolved TraceEventKind = iota
+	//TraceHookDetected is sent when a function stub doesn't start with HookCheck. Detail has the bytes that were found.
+	TraceHookDetected
+	//TraceHalosGate is sent when a sysid was deduced from a neighbouring stub. Detail has how far away the neighbour was.
+	TraceHalosGate
+	//TraceFallback is sent when Auto mode gives up on memory and falls back to reading the module from disk.
+	TraceFallback
+	//TraceVersionMismatch is sent when Auto mode falls back to a disk copy that isn't the same build as the loaded module. Detail says what differs.
+	TraceVersionMismatch
+	//TraceSyscall is sent after each Syscall/SyscallRecycledGate, if syscall tracing was asked for. Status and Args are set.
+	TraceSyscall
+	//TraceCacheWriteFailed is sent when the persistent cache file couldn't be written. Detail has the path and Err what went wrong.
+	TraceCacheWriteFailed
+)
+
+func (k TraceEventKind) String() string {
+	switch k {
+	case TraceResolved:
+		return "resolved"
+	case TraceHookDetected:
+		return "hook detected"
+	case TraceHalosGate:
+		return "halos gate"
+	case TraceFallback:
+		return "fallback"
+	case TraceVersionMismatch:
+		return "version mismatch"
+	case TraceSyscall:
+		return "syscall"
+	case TraceCacheWriteFailed:
+		return "cache write failed"
+	}
+	return fmt.Sprintf("TraceEventKind(%d)", int(k))
+}
+
+//TraceEvent is passed to the function set with SetTraceFunc.
+type TraceEvent struct {
+	Kind TraceEventKind
+	//Mode is the mode of the phone doing the resolving. Not set for syscalls.
+	Mode PhoneMode
+	//Func is the function being resolved, ordinals are written as "#123". Not set for syscalls.
+	Func   string
+	SysID  uint16
+	Status uint32
+	Args   []uintptr
+	Detail string
+	Err    error
+}
+
+//TraceFunc receives trace events. It's called synchronously on the goroutine doing the work, with phone locks held, so keep it quick and don't call back into the phone.
+type TraceFunc func(TraceEvent)
+
+type traceConfig struct {
+	fn       TraceFunc
+	syscalls bool
+}
+
+var traceHook atomic.Value //traceConfig
+
+//SetTraceFunc sets a function to be called on resolution events (what was resolved and how, hooks found, fallbacks taken). If syscalls is true it's also called after every syscall, which is noisy. Pass nil to turn tracing off.
+func SetTraceFunc(fn TraceFunc, syscalls bool) {
+	traceHook.Store(traceConfig{fn: fn, syscalls: syscalls})
+}
+
+//trace sends ev to the trace function, if there is one.
+func trace(ev TraceEvent) {
+	if ev.Kind == TraceResolved {
+		rememberSysIDName(ev.SysID, ev.Func)
+	}
+	c, _ := traceHook.Load().(traceConfig)
+	if c.fn != nil && (ev.Kind != TraceSyscall || c.syscalls) {
+		c.fn(ev)
+	}
+}
+
+//tracingSyscalls returns true if syscall events are wanted, so the args don't get copied for nothing.
+func tracingSyscalls() bool {
+	c, _ := traceHook.Load().(traceConfig)
+	return c.fn != nil && c.syscalls
+}
+
+//traceSyscall reports a finished syscall.
+func traceSyscall(sysid uint16, argh []uintptr, status uint32, err error) {
+	recordSyscall(sysid, argh, status)
+	if !tracingSyscalls() {
+		return
+	}
+	trace(TraceEvent{Kind: TraceSyscall, SysID: sysid, Args: append([]uintptr(nil), argh...), Status: status, Err: err})
+}
@@ -0,0 +1,126 @@
+package bananaphone
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//maxRecordedArgs is how many args of each syscall EnableSyscallTrace keeps. Nothing takes more than this many that you'd want to read in a dump anyway.
+const maxRecordedArgs = 12
+
+//SyscallRecord is one syscall recorded by EnableSyscallTrace.
+type SyscallRecord struct {
+	Time  time.Time
+	SysID uint16
+	//Name is the function the sysid was last resolved from by any phone, if known.
+	Name string
+	//Args holds at most the first 12 args.
+	Args []uintptr
+	//NArgs is how many args were actually passed.
+	NArgs  int
+	Status uint32
+}
+
+func (r SyscallRecord) String() string {
+	name := r.Name
+	if name == "" {
+		name = "?"
+	}
+	more := ""
+	if r.NArgs > len(r.Args) {
+		more = fmt.Sprintf(" (+%d more)", r.NArgs-len(r.Args))
+	}
+	return fmt.Sprintf("%s %s(%#x) %#x%s -> %#08x", r.Time.Format("15:04:05.000000"), name, r.SysID, r.Args, more, r.Status)
+}
+
+var syscallTrace struct {
+	enabled int32 //atomic, so untraced syscalls don't need the lock
+
+	mu    sync.Mutex
+	buf   []SyscallRecord
+	next  int
+	full  bool
+	names map[uint16]string
+}
+
+//EnableSyscallTrace starts recording the last n syscalls made with Syscall or SyscallRecycledGate into an in-memory ring buffer, see DumpTrace. Calling it again resizes the buffer, throwing away what's in it.
+func EnableSyscallTrace(n int) {
+	if n <= 0 {
+		DisableSyscallTrace()
+		return
+	}
+	t := &syscallTrace
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = make([]SyscallRecord, n)
+	t.next = 0
+	t.full = false
+	atomic.StoreInt32(&t.enabled, 1)
+}
+
+//DisableSyscallTrace stops recording syscalls and throws away the buffer.
+func DisableSyscallTrace() {
+	t := &syscallTrace
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	atomic.StoreInt32(&t.enabled, 0)
+	t.buf = nil
+	t.next = 0
+	t.full = false
+}
+
+//DumpTrace returns a copy of the recorded syscalls, oldest first. It's empty unless EnableSyscallTrace has been called.
+func DumpTrace() []SyscallRecord {
+	t := &syscallTrace
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ret := []SyscallRecord{}
+	if t.full {
+		ret = append(ret, t.buf[t.next:]...)
+	}
+	return append(ret, t.buf[:t.next]...)
+}
+
+//recordSyscall adds a syscall to the ring buffer, if it's enabled.
+func recordSyscall(sysid uint16, argh []uintptr, status uint32) {
+	t := &syscallTrace
+	if atomic.LoadInt32(&t.enabled) == 0 {
+		return
+	}
+	n := len(argh)
+	if n > maxRecordedArgs {
+		n = maxRecordedArgs
+	}
+	rec := SyscallRecord{
+		Time:   time.Now(),
+		SysID:  sysid,
+		Args:   append([]uintptr(nil), argh[:n]...),
+		NArgs:  len(argh),
+		Status: status,
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.buf) == 0 {
+		return
+	}
+	rec.Name = t.names[sysid]
+	t.buf[t.next] = rec
+	t.next++
+	if t.next == len(t.buf) {
+		t.next = 0
+		t.full = true
+	}
+}
+
+//rememberSysIDName records which function a sysid was resolved from, so recorded syscalls can be named.
+func rememberSysIDName(sysid uint16, name string) {
+	t := &syscallTrace
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.names == nil {
+		t.names = make(map[uint16]string)
+	}
+	t.names[sysid] = name
+}
@@ -0,0 +1,79 @@
+package bananaphone
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unsafe"
+)
+
+//UnicodeString is the UNICODE_STRING structure used by pretty much every Nt function that takes a name. Unlike the internal stupidstring, this one is intended to be built by you and passed into syscalls.
+type UnicodeString struct {
+	Length        uint16
+	MaximumLength uint16
+	Buffer        *uint16
+}
+
+//NewUnicodeString converts s into a UnicodeString. The lengths are 16 bit byte counts, so strings of 32767 or more UTF-16 units are an error. The UTF-16 backing buffer is referenced by the Buffer field, so it stays alive for as long as the returned value does.
+func NewUnicodeString(s string) (*UnicodeString, error) {
+	if strings.IndexByte(s, 0) != -1 {
+		return nil, errors.New("string contains a null byte")
+	}
+	buf := utf16.Encode([]rune(s + "\x00"))
+	if len(buf)*2 > 0xffff {
+		return nil, fmt.Errorf("string is too long for a UNICODE_STRING: %d UTF-16 units", len(buf)-1)
+	}
+	//Length is in bytes and excludes the null terminator, MaximumLength includes it
+	return &UnicodeString{
+		Length:        uint16((len(buf) - 1) * 2),
+		MaximumLength: uint16(len(buf) * 2),
+		Buffer:        &buf[0],
+	}, nil
+}
+
+//String returns the Go string representation of the UnicodeString.
+func (u *UnicodeString) String() string {
+	if u == nil || u.Buffer == nil {
+		return ""
+	}
+	return string(utf16.Decode(uint16Slice(uintptr(unsafe.Pointer(u.Buffer)), int(u.Length/2))))
+}
+
+//ObjectAttributes is the OBJECT_ATTRIBUTES structure. Length must be set to the size of the struct, use NewObjectAttributes to avoid forgetting.
+type ObjectAttributes struct {
+	Length                   uint32
+	RootDirectory            uintptr
+	ObjectName               *UnicodeString
+	Attributes               uint32
+	SecurityDescriptor       uintptr
+	SecurityQualityOfService uintptr
+}
+
+//NewObjectAttributes builds an ObjectAttributes for the provided object name and attribute flags (eg OBJ_CASE_INSENSITIVE). An empty name leaves ObjectName nil, which is what most calls expect for unnamed objects.
+func NewObjectAttributes(name string, flags uint32) (*ObjectAttributes, error) {
+	oa := &ObjectAttributes{
+		Length:     uint32(unsafe.Sizeof(ObjectAttributes{})),
+		Attributes: flags,
+	}
+	if name != "" {
+		us, e := NewUnicodeString(name)
+		if e != nil {
+			return nil, e
+		}
+		oa.ObjectName = us
+	}
+	return oa, nil
+}
+
+//ClientID is the CLIENT_ID structure, identifying a process and (optionally) a thread. Used by NtOpenProcess/NtOpenThread.
+type ClientID struct {
+	UniqueProcess uintptr
+	UniqueThread  uintptr
+}
+
+//IoStatusBlock is the IO_STATUS_BLOCK structure filled in by file and device calls. Status overlaps with the Pointer member of the union in the native definition.
+type IoStatusBlock struct {
+	Status      uintptr
+	Information uintptr
+}
@@ -0,0 +1,67 @@
+package bananaphone
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	validateSysIDs int32 //atomic bool, see SetSysIDValidation
+
+	syscallCountOnce sync.Once
+	syscallCount     uint16
+)
+
+//SetSysIDValidation turns checking of the sysids passed to Syscall and SyscallRecycledGate on or off. It's off by default. With it on, 0 is refused, because that's what an unresolved sysid (eg from ignoring a GetSysID error) usually is - and 0 is a real syscall (NtAccessCheck on every build), not a harmless no-op. Anything past the end of this build's ntdll syscall table is refused too. win32k sysids (0x1000 and up) aren't checked. A refused call makes no syscall and returns STATUS_INVALID_SYSTEM_SERVICE with an error wrapping ErrInvalidSysID.
+func SetSysIDValidation(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&validateSysIDs, v)
+}
+
+//checkSysID returns an error if validation is on and callid should be refused.
+func checkSysID(callid uint16) error {
+	if atomic.LoadInt32(&validateSysIDs) == 0 {
+		return nil
+	}
+	if callid == 0 {
+		return fmt.Errorf("%w: 0 (probably unresolved, see SetSysIDValidation)", ErrInvalidSysID)
+	}
+	if callid >= 0x1000 {
+		return nil
+	}
+	syscallCountOnce.Do(func() {
+		syscallCount = countSyscalls()
+	})
+	if syscallCount != 0 && callid >= syscallCount {
+		return fmt.Errorf("%w: %#x (this build has %#x syscalls)", ErrInvalidSysID, callid, syscallCount)
+	}
+	return nil
+}
+
+//countSyscalls returns how many syscalls the loaded ntdll has, which is the number of Zw* exports (every syscall has exactly one, and they're numbered from 0). Returns 0 if it can't be worked out.
+func countSyscalls() uint16 {
+	base, size, e := GetModuleBase("ntdll.dll")
+	if e != nil {
+		return 0
+	}
+	p, e := parsePE(base, size)
+	if e != nil {
+		return 0
+	}
+	exports, e := peExports(p)
+	if e != nil {
+		return 0
+	}
+	n := uint16(0)
+	for _, ex := range exports {
+		if strings.HasPrefix(ex.Name, "Zw") {
+			n++
+		}
+	}
+	return n
+}
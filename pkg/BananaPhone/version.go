@@ -0,0 +1,113 @@
+package bananaphone
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Binject/debug/pe"
+)
+
+//vsFixedFileInfoSignature is the first field of VS_FIXEDFILEINFO, as it appears in memory.
+var vsFixedFileInfoSignature = []byte{0xbd, 0x04, 0xef, 0xfe}
+
+//FileVersion is the file version from a module's VS_FIXEDFILEINFO resource, eg 10.0.19041.3636.
+type FileVersion struct {
+	Major, Minor, Build, Revision uint16
+}
+
+func (v FileVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d.%d", v.Major, v.Minor, v.Build, v.Revision)
+}
+
+//ModuleVersion returns the file version of the module the phone resolves against (which is the disk copy if Auto mode has fallen back).
+func (b *BananaPhone) ModuleVersion() (FileVersion, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.banana == nil {
+		return FileVersion{}, errors.New("bananaphone has been closed")
+	}
+	return moduleVersion(b.banana)
+}
+
+//PEFileVersion returns the file version of any parsed module, eg one opened from disk with pe.Open.
+func PEFileVersion(p *pe.File) (FileVersion, error) {
+	return moduleVersion(p)
+}
+
+//ModuleTimestamp returns the TimeDateStamp and CheckSum from the headers of the module the phone resolves against.
+func (b *BananaPhone) ModuleTimestamp() (timeDateStamp, checksum uint32, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.banana == nil {
+		return 0, 0, errors.New("bananaphone has been closed")
+	}
+	return b.banana.FileHeader.TimeDateStamp, moduleChecksum(b.banana), nil
+}
+
+//moduleChecksum returns the CheckSum from the optional header.
+func moduleChecksum(p *pe.File) uint32 {
+	switch oh := p.OptionalHeader.(type) {
+	case *pe.OptionalHeader64:
+		return oh.CheckSum
+	case *pe.OptionalHeader32:
+		return oh.CheckSum
+	}
+	return 0
+}
+
+//moduleVersion finds the VS_FIXEDFILEINFO in the resource section and returns the file version from it. Rather than walking the resource tree, the section is searched for the structure's signature - there's only one version resource in a dll.
+func moduleVersion(p *pe.File) (FileVersion, error) {
+	var dd pe.DataDirectory
+	switch oh := p.OptionalHeader.(type) {
+	case *pe.OptionalHeader64:
+		if oh.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_RESOURCE {
+			dd = oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_RESOURCE]
+		}
+	case *pe.OptionalHeader32:
+		if oh.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_RESOURCE {
+			dd = oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_RESOURCE]
+		}
+	}
+	if dd.VirtualAddress == 0 {
+		return FileVersion{}, errors.New("module has no resources")
+	}
+	s := sectionForRVA(p, dd.VirtualAddress)
+	if s == nil {
+		return FileVersion{}, fmt.Errorf("resource rva %x is not in any section", dd.VirtualAddress)
+	}
+	data, e := s.Data()
+	if e != nil {
+		return FileVersion{}, e
+	}
+	for off := 0; ; {
+		i := bytes.Index(data[off:], vsFixedFileInfoSignature)
+		if i < 0 {
+			return FileVersion{}, errors.New("module has no version resource")
+		}
+		off += i
+		//the structure is dword aligned, and the file version is in the 3rd and 4th dwords (MS then LS)
+		if off%4 == 0 && off+16 <= len(data) {
+			ms := binary.LittleEndian.Uint32(data[off+8:])
+			ls := binary.LittleEndian.Uint32(data[off+12:])
+			return FileVersion{uint16(ms >> 16), uint16(ms), uint16(ls >> 16), uint16(ls)}, nil
+		}
+		off++
+	}
+}
+
+//versionMismatch compares two copies of a module, returning a description of what differs or an empty string if they look like the same build.
+func versionMismatch(a, b *pe.File) string {
+	diffs := []string{}
+	if a.FileHeader.TimeDateStamp != b.FileHeader.TimeDateStamp {
+		diffs = append(diffs, fmt.Sprintf("timestamp %08x != %08x", a.FileHeader.TimeDateStamp, b.FileHeader.TimeDateStamp))
+	}
+	va, ea := moduleVersion(a)
+	vb, eb := moduleVersion(b)
+	if ea == nil && eb == nil && va != vb {
+		diffs = append(diffs, fmt.Sprintf("version %s != %s", va, vb))
+	}
+	return strings.Join(diffs, ", ")
+}